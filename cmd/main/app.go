@@ -12,13 +12,17 @@ import (
 	drinks_list_api "restapi/internal/adapters/api/drinks_list"
 	event_api "restapi/internal/adapters/api/event"
 	ingredients_api "restapi/internal/adapters/api/ingredients"
+	tab_api "restapi/internal/adapters/api/tab"
 	user_api "restapi/internal/adapters/api/user"
 	bar_db "restapi/internal/adapters/db/bar"
+	broker_db "restapi/internal/adapters/db/broker"
 	drinks_list_db "restapi/internal/adapters/db/drinks_list"
 	event_db "restapi/internal/adapters/db/event"
 	ingredients_db "restapi/internal/adapters/db/ingredients"
 	menu_db "restapi/internal/adapters/db/menu"
+	order_db "restapi/internal/adapters/db/order"
 	session_db "restapi/internal/adapters/db/session"
+	tab_db "restapi/internal/adapters/db/tab"
 	user_db "restapi/internal/adapters/db/user"
 	"restapi/internal/config"
 	"restapi/internal/domain/bar"
@@ -26,6 +30,8 @@ import (
 	"restapi/internal/domain/event"
 	"restapi/internal/domain/ingredients"
 	"restapi/internal/domain/menu"
+	"restapi/internal/domain/order"
+	"restapi/internal/domain/tab"
 	"restapi/internal/domain/user"
 	"restapi/pkg/auth"
 	"restapi/pkg/client/postgresql"
@@ -47,9 +53,6 @@ func main() {
 
 	hasher := hash.NewSHA1Hasher("passHasherSalt123")
 
-	// неправильно это, но пока для теста:
-	hub := bar_api.NewHub()
-
 	tokenManager, err := auth.NewManager(cfg.Tokens.SigningKey)
 	if err != nil {
 		logger.Fatalf("failed to create token manager: %v", err)
@@ -60,6 +63,15 @@ func main() {
 		logger.Fatalf("%v", err)
 	}
 
+	// Хаб один на все бары, сессии баров открываются и закрываются через bar.Service.
+	// Сообщения хаба расходятся по всем экземплярам приложения через Postgres NOTIFY
+	logger.Info("creating bar hub")
+	hub, err := bar_api.NewHub(broker_db.NewBroker(postgreSQLClient, cfg.WebSocket.NotifyChannel, logger))
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	go hub.Run()
+
 	logger.Info("creating user repository")
 	userRepository := user_db.NewRepository(postgreSQLClient, logger, hasher)
 
@@ -81,25 +93,43 @@ func main() {
 	logger.Info("creating drinks_list repository")
 	drinks_listRepository := drinks_list_db.NewRepository(postgreSQLClient, logger)
 
+	logger.Info("creating order repository")
+	orderRepository := order_db.NewRepository(postgreSQLClient, logger)
+
+	logger.Info("creating tab repository")
+	tabRepository := tab_db.NewRepository(postgreSQLClient, logger)
+
 	logger.Info("register user service")
 	userService := user.NewService(userRepository, sessionRepository, logger, hasher, tokenManager,
 		cfg.Tokens.AccessTokenTTL, cfg.Tokens.RefreshTokenTTL)
 
 	logger.Info("register event service")
-	eventService := event.NewService(eventRepository, menuRepository, logger)
+	eventService := event.NewService(eventRepository, menuRepository, orderRepository,
+		cfg.Event.DrinksPerGuest, logger)
+
+	// Активация и завершение ивентов, запланированные до перезапуска
+	if err := eventService.StartScheduler(context.TODO()); err != nil {
+		logger.Fatalf("%v", err)
+	}
 
 	logger.Info("register ingredients service")
 	ingredientsService := ingredients.NewService(ingredientsRepository, eventRepository, logger)
 
 	logger.Info("register bar service")
-	barService := bar.NewService(barRepository, logger)
+	barService := bar.NewService(barRepository, orderRepository, menuRepository, hub, cfg.WebSocket.JoinURL, logger)
 
 	logger.Info("register menu service")
-	menuService := menu.NewService(menuRepository, logger)
+	menuService := menu.NewService(menuRepository, eventService, logger)
 
 	logger.Info("register drinks_list service")
 	drinks_listService := drinks_list.NewService(drinks_listRepository, logger)
 
+	logger.Info("register order service")
+	orderService := order.NewService(orderRepository, menuRepository, tabRepository, logger)
+
+	logger.Info("register tab service")
+	tabService := tab.NewService(tabRepository, logger)
+
 	logger.Info("register user handler")
 	userHandler := user_api.NewHandler(logger, userService, eventService, barService, menuService)
 
@@ -110,16 +140,28 @@ func main() {
 	ingredientsHandler := ingredients_api.NewHandler(logger, ingredientsService)
 
 	logger.Info("register bar handler")
-	barHandler := bar_api.NewHandler(logger, barService, userService, hub)
+	barHandler := bar_api.NewHandler(logger, barService, userService, orderService, eventService,
+		tokenManager, hub, cfg.WebSocket.AllowedOrigins, cfg.WebSocket.GuestPageURL)
+
+	// Статистика баров рассылается, пока работает приложение
+	statsCtx, stopStats := context.WithCancel(context.Background())
+	defer stopStats()
+
+	logger.Info("starting bar stats pusher")
+	go bar_api.NewStatsPusher(hub, barService, orderService, logger).Run(statsCtx)
 
 	logger.Info("register drinks_list handler")
 	drinks_listHandler := drinks_list_api.NewHandler(logger, drinks_listService)
 
+	logger.Info("register tab handler")
+	tabHandler := tab_api.NewHandler(logger, tabService, userService)
+
 	userHandler.Register(router)
 	eventHandler.Register(router)
 	barHandler.Register(router)
 	ingredientsHandler.Register(router)
 	drinks_listHandler.Register(router)
+	tabHandler.Register(router)
 
 	start(router, cfg)
 }
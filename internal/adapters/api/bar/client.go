@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -25,14 +24,28 @@ const (
 	maxMessageSize = 512
 )
 
-var (
-	newline = []byte{'\n'}
-	space   = []byte{' '}
-)
+// newUpgrader принимает подключения только с разрешенных origin.
+// Если список пуст, используется проверка gorilla/websocket на совпадение origin и host
+func newUpgrader(allowedOrigins []string) websocket.Upgrader {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+
+	if len(allowedOrigins) == 0 {
+		return upgrader
+	}
+
+	origins := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		origins[origin] = true
+	}
+
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		return origins[r.Header.Get("Origin")]
+	}
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+	return upgrader
 }
 
 // Client is a middleman(посредник) between the websocket connection and the hub.
@@ -44,6 +57,17 @@ type Client struct {
 
 	// Buffered channel of outbound(исходящих) messages.
 	send chan []byte
+
+	// Комнаты клиента: ивент и бар (0, если клиент подключен ко всему ивенту)
+	eventID string
+	barID   uint32
+
+	// Кто подключен: id пользователя (пустой у гостя) и его роль
+	userID string
+	role   string
+
+	// Обработчик входящих сообщений, прошедших проверку
+	process func(c *Client, env Envelope) error
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -67,9 +91,42 @@ func (c *Client) readPump() {
 			}
 			break
 		}
-		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
-		c.hub.broadcast <- message
+		message = bytes.TrimSpace(message)
+
+		env, err := decodeEnvelope(message)
+		if err != nil {
+			c.sendError(env.ID, err)
+			continue
+		}
+
+		if !canSend(c.role, env.Type) {
+			c.sendError(env.ID, fmt.Errorf("message type %s is not allowed for %s", env.Type, c.role))
+			continue
+		}
+
+		if err := c.process(c, env); err != nil {
+			c.sendError(env.ID, err)
+			continue
+		}
+
+		if env.Type != msgAck {
+			c.sendEnvelope(msgAck, AckPayload{MessageID: env.ID})
+		}
+	}
+}
+
+func (c *Client) sendError(messageID string, err error) {
+	c.sendEnvelope(msgError, ErrorPayload{MessageID: messageID, Message: err.Error()})
+}
+
+func (c *Client) sendEnvelope(msgType string, payload any) {
+	message, err := newEnvelope(msgType, payload)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return
 	}
+
+	c.hub.SendToClient(c, message)
 }
 
 // writePump pumps messages from the hub to the websocket connection.
@@ -93,30 +150,8 @@ func (c *Client) writePump() {
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-
-			// я решил добавить время отправки
-			// 15:04:05
-
-			split1 := strings.Split(time.Now().String(), " ")
-
-			split2 := strings.Split(split1[1], ".")
-
-			message = append(message, []byte(fmt.Sprintf("\t| %s |", split2[0]))...)
-
-			w.Write(message)
-
-			// Add queued chat messages to the current websocket message.
-			n := len(c.send)
-			for i := 0; i < n; i++ {
-				w.Write(newline)
-				w.Write(<-c.send)
-			}
-
-			if err := w.Close(); err != nil {
+			// Каждый конверт отправляется отдельным websocket-сообщением
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				return
 			}
 		case <-ticker.C:
@@ -129,13 +164,16 @@ func (c *Client) writePump() {
 }
 
 // serveWs handles websocket requests from the peer.
-func serveWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
+// Комнаты и роль клиента уже проверены обработчиком
+func serveWs(hub *Hub, upgrader websocket.Upgrader, client *Client, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256)}
+	client.hub = hub
+	client.conn = conn
+	client.send = make(chan []byte, 256)
 	client.hub.register <- client
 
 	// Allow collection of memory referenced by the caller by doing all work in
@@ -3,15 +3,22 @@ package bar_api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"restapi/internal/adapters"
 	"restapi/internal/apperror"
 	"restapi/internal/domain/bar"
+	"restapi/internal/domain/event"
+	"restapi/internal/domain/order"
 	"restapi/internal/domain/user"
 	"strconv"
+	"time"
 
+	"restapi/pkg/auth"
 	"restapi/pkg/logging"
 
+	"github.com/gorilla/websocket"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -22,39 +29,96 @@ const (
 	createBarURL = "/api/bar/create"
 	closeBarURL  = "/api/bar/close"
 	updateBarURL = "/api/bar/update"
+	getBarURL    = "/api/bar"
+	getBarsURL   = "/api/bars"
+
+	barStaffURL  = "/api/bar/staff"
+	userStaffURL = "/api/bar/staff/me"
+
+	drinkAvailabilityURL = "/api/bar/drink/availability"
+	unavailableDrinksURL = "/api/bar/drinks/unavailable"
+
+	barQRURL    = "/api/bar/qr"
+	barCardsURL = "/api/bar/cards"
 
 	getBarOrdersURL = "/api/bar/orders"
+	getBarQueueURL  = "/api/bar/queue"
+	getOrderURL     = "/api/bar/order"
+	placeOrderURL   = "/api/bar/order"
+
+	orderStatusURL       = "/api/bar/order/status"
+	getStuckOrdersURL    = "/api/bar/orders/stuck"
+	getOrderStageStatURL = "/api/bar/orders/stages"
+	getBarStatsURL       = "/api/bar/stats"
 
 	wsConnectionURL = "/api/bar/ws"
+	sseStreamURL    = "/api/bar/events"
+
+	// Сколько пропущенных сообщений переотправляется за один resume.
+	// Должно быть меньше буфера отправки клиента, иначе хаб отключит клиента
+	replayLimit = 200
 )
 
 type handler struct {
-	userService user.Service
-	service     bar.Service
-	logger      *logging.Logger
-	hub         *Hub
+	userService  user.Service
+	orderService order.Service
+	eventService event.Service
+	service      bar.Service
+	tokenManager auth.TokenManager
+	logger       *logging.Logger
+	hub          *Hub
+	upgrader     websocket.Upgrader
+	guestPageURL string
 }
 
-func NewHandler(logger *logging.Logger, service bar.Service, userService user.Service, hub *Hub) adapters.Handler {
+func NewHandler(logger *logging.Logger, service bar.Service, userService user.Service,
+	orderService order.Service, eventService event.Service, tokenManager auth.TokenManager,
+	hub *Hub, allowedOrigins []string, guestPageURL string) adapters.Handler {
 	return &handler{
-		service:     service,
-		userService: userService,
-		logger:      logger,
-		hub:         hub,
+		service:      service,
+		userService:  userService,
+		orderService: orderService,
+		eventService: eventService,
+		tokenManager: tokenManager,
+		logger:       logger,
+		hub:          hub,
+		upgrader:     newUpgrader(allowedOrigins),
+		guestPageURL: guestPageURL,
 	}
 }
 
 func (h *handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, createBarURL, apperror.Middleware(h.Verify(h.CreateBar)))
 	router.HandlerFunc(http.MethodDelete, closeBarURL, apperror.Middleware(h.Verify(h.CloseBar)))
+	router.HandlerFunc(http.MethodGet, getBarURL, apperror.Middleware(h.Verify(h.GetBar)))
+	router.HandlerFunc(http.MethodGet, getBarsURL, apperror.Middleware(h.Verify(h.GetEventBars)))
+	router.HandlerFunc(http.MethodPost, barStaffURL, apperror.Middleware(h.Verify(h.AssignStaff)))
+	router.HandlerFunc(http.MethodDelete, barStaffURL, apperror.Middleware(h.Verify(h.UnassignStaff)))
+	router.HandlerFunc(http.MethodGet, barStaffURL, apperror.Middleware(h.Verify(h.GetBarStaff)))
+	router.HandlerFunc(http.MethodGet, userStaffURL, apperror.Middleware(h.Verify(h.GetUserStaff)))
+	router.HandlerFunc(http.MethodPatch, drinkAvailabilityURL, apperror.Middleware(h.Verify(h.SetDrinkAvailability)))
+
+	// Гости видят, какие напитки сейчас нельзя заказать
+	router.HandlerFunc(http.MethodGet, unavailableDrinksURL, apperror.Middleware(h.GetUnavailableDrinks))
+
+	router.HandlerFunc(http.MethodGet, barQRURL, apperror.Middleware(h.Verify(h.GetBarQR)))
+	router.HandlerFunc(http.MethodGet, barCardsURL, apperror.Middleware(h.Verify(h.GetBarCards)))
 	router.HandlerFunc(http.MethodGet, getBarOrdersURL, apperror.Middleware(h.Verify(h.GetBarOrders)))
+	router.HandlerFunc(http.MethodGet, getOrderURL, apperror.Middleware(h.Verify(h.GetOrder)))
+	router.HandlerFunc(http.MethodGet, getBarQueueURL, apperror.Middleware(h.Verify(h.GetBarQueue)))
+
+	// Заказы делают гости, у которых нет аккаунта
+	router.HandlerFunc(http.MethodPost, placeOrderURL, apperror.Middleware(h.PlaceOrder))
+	router.HandlerFunc(http.MethodPatch, orderStatusURL, apperror.Middleware(h.Verify(h.ChangeOrderStatus)))
+	router.HandlerFunc(http.MethodGet, getStuckOrdersURL, apperror.Middleware(h.Verify(h.GetStuckOrders)))
+	router.HandlerFunc(http.MethodGet, getOrderStageStatURL, apperror.Middleware(h.Verify(h.GetOrderStageStats)))
+	router.HandlerFunc(http.MethodGet, getBarStatsURL, apperror.Middleware(h.Verify(h.GetBarStats)))
 	router.HandlerFunc(http.MethodPost, updateBarURL, apperror.Middleware(h.Verify(h.UpdateBar)))
-	router.HandlerFunc(http.MethodGet, wsConnectionURL, func(w http.ResponseWriter, r *http.Request) {
-		go h.hub.Run()
-		serveWs(h.hub, w, r)
-	})
+	router.HandlerFunc(http.MethodGet, wsConnectionURL, h.ServeWs)
+	router.HandlerFunc(http.MethodGet, sseStreamURL, h.ServeSSE)
 }
 
+// Открывать, закрывать и изменять бары может только организатор ивента
 func (h *handler) CreateBar(w http.ResponseWriter, r *http.Request) error {
 	var dto bar.CreateBarDTO
 
@@ -63,13 +127,18 @@ func (h *handler) CreateBar(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	barID, err2 := h.service.OpenBar(context.TODO(), dto)
+	if err := h.eventHost(r, dto.EventID); err != nil {
+		return err
+	}
+
+	br, err2 := h.service.OpenBar(context.TODO(), dto)
 	if err2 != nil {
 		return err2
 	}
 
 	resp := bar.RespCreateBar{
-		ID: barID,
+		ID:         br.ID,
+		SessionURL: br.SessionURL,
 	}
 
 	respBytes, err := json.Marshal(resp)
@@ -91,6 +160,15 @@ func (h *handler) CloseBar(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	role, err := h.barRole(r, dto.ID)
+	if err != nil {
+		return err
+	}
+
+	if role != roleHost {
+		return apperror.ErrForbidden
+	}
+
 	err = h.service.CloseBar(context.TODO(), dto)
 	if err != nil {
 		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
@@ -102,6 +180,262 @@ func (h *handler) CloseBar(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+func (h *handler) GetBar(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.FindBarDTO
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	dto.ID = uint32(id)
+
+	userID, err := h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	br, err := h.service.FindBar(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	// Ссылку сессии видят только организатор и сотрудники бара
+	if h.userRole(context.TODO(), userID, br.EventID, br.ID) == roleGuest {
+		hideSession(&br)
+	}
+
+	barBytes, err := json.Marshal(br)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(barBytes)
+
+	return nil
+}
+
+// Query param status (Opened или Closed) необязательный
+func (h *handler) GetEventBars(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.FindEventBarsDTO
+
+	dto.EventID = r.URL.Query().Get("event_id")
+	dto.Status = r.URL.Query().Get("status")
+
+	if dto.EventID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	userID, err := h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	bars, err := h.service.FindEventBars(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	// Организатор видит ссылки сессий всех баров, сотрудник - только своего
+	if h.userRole(context.TODO(), userID, dto.EventID, 0) != roleHost {
+		for i := range bars {
+			member := bar.FindStaffMemberDTO{BarID: bars[i].ID, UserID: userID}
+			if _, err := h.service.FindStaffMember(context.TODO(), member); err != nil {
+				hideSession(&bars[i])
+			}
+		}
+	}
+
+	barsBytes, err := json.Marshal(bars)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(barsBytes)
+
+	return nil
+}
+
+// Назначать сотрудников может только организатор ивента бара
+func (h *handler) AssignStaff(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.AssignStaffDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	role, err := h.barRole(r, dto.BarID)
+	if err != nil {
+		return err
+	}
+
+	if role != roleHost {
+		return apperror.ErrForbidden
+	}
+
+	member, err := h.service.AssignStaff(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong staff data", err.Error(), "US-000009")
+	}
+
+	memberBytes, err := json.Marshal(member)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(memberBytes)
+
+	return nil
+}
+
+func (h *handler) UnassignStaff(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.UnassignStaffDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	role, err := h.barRole(r, dto.BarID)
+	if err != nil {
+		return err
+	}
+
+	if role != roleHost {
+		return apperror.ErrForbidden
+	}
+
+	err = h.service.UnassignStaff(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong staff data", err.Error(), "US-000009")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("staff member is unassigned"))
+
+	return nil
+}
+
+func (h *handler) GetBarStaff(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.FindBarStaffDTO
+
+	id, err := strconv.Atoi(r.URL.Query().Get("bar_id"))
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	dto.BarID = uint32(id)
+
+	if _, err := h.barRole(r, dto.BarID); err != nil {
+		return err
+	}
+
+	staff, err := h.service.FindBarStaff(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	staffBytes, err := json.Marshal(staff)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(staffBytes)
+
+	return nil
+}
+
+// Бары, на которые назначен текущий пользователь
+func (h *handler) GetUserStaff(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.FindUserStaffDTO
+	var err error
+
+	dto.UserID, err = h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	staff, err := h.service.FindUserStaff(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	staffBytes, err := json.Marshal(staff)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(staffBytes)
+
+	return nil
+}
+
+// Отмечать доступность напитков может любой сотрудник бара и организатор
+func (h *handler) SetDrinkAvailability(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.SetDrinkAvailabilityDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	if _, err := h.barRole(r, dto.BarID); err != nil {
+		return err
+	}
+
+	dto.UserID, err = h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	availability, err := h.setDrinkAvailability(dto, "")
+	if err != nil {
+		return apperror.NewAppError(err, "wrong drink availability data", err.Error(), "US-000009")
+	}
+
+	availabilityBytes, err := json.Marshal(availability)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(availabilityBytes)
+
+	return nil
+}
+
+func (h *handler) GetUnavailableDrinks(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.FindUnavailableDrinksDTO
+
+	id, err := strconv.Atoi(r.URL.Query().Get("bar_id"))
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	dto.BarID = uint32(id)
+
+	drinks, err := h.service.FindUnavailableDrinks(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	drinksBytes, err := json.Marshal(drinks)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(drinksBytes)
+
+	return nil
+}
+
 func (h *handler) GetBarOrders(w http.ResponseWriter, r *http.Request) error {
 	var dto bar.GetBarOrdersDTO
 
@@ -117,6 +451,10 @@ func (h *handler) GetBarOrders(w http.ResponseWriter, r *http.Request) error {
 		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
 	}
 
+	if _, err := h.barRole(r, dto.ID); err != nil {
+		return err
+	}
+
 	barOrders, err := h.service.GetBarOrders(context.TODO(), dto)
 	if err != nil {
 		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
@@ -133,45 +471,616 @@ func (h *handler) GetBarOrders(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func (h *handler) UpdateBar(w http.ResponseWriter, r *http.Request) error {
-	var dto bar.UpdateBarDTO
+func (h *handler) PlaceOrder(w http.ResponseWriter, r *http.Request) error {
+	var dto order.CreateOrderDTO
 
 	err := json.NewDecoder(r.Body).Decode(&dto)
 	if err != nil {
 		return err
 	}
 
-	err = h.service.UpdateInfo(context.TODO(), dto)
+	ordr, err := h.orderService.NewOrder(context.TODO(), dto)
 	if err != nil {
-		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+		return apperror.NewAppError(err, "wrong order data", err.Error(), "US-000009")
+	}
+
+	h.broadcastOrder(msgOrderCreated, ordr)
+
+	orderBytes, err := json.Marshal(ordr)
+	if err != nil {
+		return err
 	}
 
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("bar info is updated"))
+	w.Write(orderBytes)
 
 	return nil
 }
 
-func (h *handler) Verify(protectedHandler apperror.AppHandler) apperror.AppHandler {
+func (h *handler) GetOrder(w http.ResponseWriter, r *http.Request) error {
+	var dto order.FindOrderDTO
 
-	return func(w http.ResponseWriter, r *http.Request) error {
-		cookie, err := r.Cookie("AccessToken")
-		if err != nil {
-			h.logger.Errorf("cookie error: %v", err)
-			return apperror.ErrUnauthorized
-		}
+	dto.ID = r.URL.Query().Get("id")
 
-		if cookie.Value == "" {
-			h.logger.Errorf("access token is empty")
-			return apperror.ErrUnauthorized
-		}
+	if dto.ID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param id is empty", "US-000015")
+	}
 
-		err = h.userService.Verify(context.TODO(), cookie.Value)
-		if err != nil {
-			h.logger.Errorf("access token is wrong: %v", err)
-			return apperror.ErrUnauthorized
-		}
+	ordr, err := h.orderService.FindOrder(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
 
-		return protectedHandler(w, r)
+	if _, err := h.barRole(r, ordr.BarID); err != nil {
+		return err
+	}
+
+	orderBytes, err := json.Marshal(ordr)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(orderBytes)
+
+	return nil
+}
+
+func (h *handler) ChangeOrderStatus(w http.ResponseWriter, r *http.Request) error {
+	var dto order.ChangeStatusDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	dto.UserID, err = h.userID(r)
+	if err != nil {
+		return err
 	}
+
+	ordr, err := h.orderService.FindOrder(context.TODO(), order.FindOrderDTO{ID: dto.ID})
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	role, err := h.barRole(r, ordr.BarID)
+	if err != nil {
+		return err
+	}
+
+	if !canChangeStatus(role) {
+		return apperror.ErrForbidden
+	}
+
+	ordr, err = h.orderService.ChangeStatus(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong order status data", err.Error(), "US-000009")
+	}
+
+	h.broadcastOrder(msgOrderStatusChanged, ordr)
+
+	orderBytes, err := json.Marshal(ordr)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(orderBytes)
+
+	return nil
+}
+
+// Query param minutes - сколько минут заказ должен провести в одном статусе,
+// чтобы считаться зависшим. По умолчанию 10
+func (h *handler) GetStuckOrders(w http.ResponseWriter, r *http.Request) error {
+	var dto order.FindStuckOrdersDTO
+
+	id, err := strconv.Atoi(r.URL.Query().Get("bar_id"))
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	dto.BarID = uint32(id)
+	dto.EventID = r.URL.Query().Get("event_id")
+
+	if dto.EventID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	if _, err := h.barRole(r, dto.BarID); err != nil {
+		return err
+	}
+
+	minutes := 10
+	if m := r.URL.Query().Get("minutes"); m != "" {
+		minutes, err = strconv.Atoi(m)
+		if err != nil {
+			return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+		}
+	}
+
+	dto.Timeout = time.Duration(minutes) * time.Minute
+
+	stuck, err := h.orderService.FindStuckOrders(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	stuckBytes, err := json.Marshal(stuck)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(stuckBytes)
+
+	return nil
+}
+
+func (h *handler) GetOrderStageStats(w http.ResponseWriter, r *http.Request) error {
+	var dto order.FindBarOrdersDTO
+
+	id, err := strconv.Atoi(r.URL.Query().Get("bar_id"))
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	dto.BarID = uint32(id)
+	dto.EventID = r.URL.Query().Get("event_id")
+
+	if dto.EventID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	if _, err := h.barRole(r, dto.BarID); err != nil {
+		return err
+	}
+
+	stats, err := h.orderService.GetStageStats(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	statsBytes, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(statsBytes)
+
+	return nil
+}
+
+func (h *handler) GetBarQueue(w http.ResponseWriter, r *http.Request) error {
+	var dto order.FindBarOrdersDTO
+
+	id, err := strconv.Atoi(r.URL.Query().Get("bar_id"))
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	dto.BarID = uint32(id)
+	dto.EventID = r.URL.Query().Get("event_id")
+
+	if dto.EventID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	if _, err := h.barRole(r, dto.BarID); err != nil {
+		return err
+	}
+
+	queue, err := h.orderService.GetBarQueue(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	queueBytes, err := json.Marshal(queue)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(queueBytes)
+
+	return nil
+}
+
+func (h *handler) UpdateBar(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.UpdateBarDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	role, err := h.barRole(r, dto.ID)
+	if err != nil {
+		return err
+	}
+
+	if role != roleHost {
+		return apperror.ErrForbidden
+	}
+
+	err = h.service.UpdateInfo(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("bar info is updated"))
+
+	return nil
+}
+
+// ServeWs проверяет клиента перед подключением к websocket.
+// К бару подключаются по ссылке его сессии (query param session), ко всему ивенту - организатор по event_id.
+// Пользователь передает AccessToken в cookie или в query param token:
+// организатор ивента подключается как host, сотрудник бара - со своей ролью в баре.
+// Остальные пользователи и подключения без токена - гости, которые только получают сообщения
+func (h *handler) ServeWs(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.wsUserID(r)
+	if err != nil {
+		h.logger.Errorf("websocket access token is wrong: %v", err)
+		http.Error(w, apperror.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	eventID, barID, err := h.wsRooms(r, userID)
+	if err != nil {
+		wsError(w, err)
+		return
+	}
+
+	client := &Client{
+		eventID: eventID,
+		barID:   barID,
+		userID:  userID,
+		role:    roleGuest,
+		process: h.processMessage,
+	}
+
+	if userID != "" {
+		client.role = h.userRole(context.TODO(), userID, eventID, barID)
+	}
+
+	h.logger.Infof("websocket client connected: event_id: %s, bar_id: %d, role: %s", eventID, barID, client.role)
+
+	serveWs(h.hub, h.upgrader, client, w, r)
+}
+
+// wsUserID возвращает id пользователя websocket- или SSE-клиента по AccessToken
+// из cookie или query param token. Для подключения без токена возвращает пустой id
+func (h *handler) wsUserID(r *http.Request) (string, error) {
+	token := r.URL.Query().Get("token")
+	if cookie, err := r.Cookie("AccessToken"); err == nil && cookie.Value != "" {
+		token = cookie.Value
+	}
+
+	if token == "" {
+		return "", nil
+	}
+
+	return h.tokenManager.Parse(token)
+}
+
+// wsRooms определяет комнаты websocket- или SSE-клиента: бар и его ивент по токену сессии бара
+// или только ивент, если клиент подключается ко всему ивенту.
+// Ко всему ивенту может подключиться только его организатор: клиент ивента получает заказы всех баров
+func (h *handler) wsRooms(r *http.Request, userID string) (string, uint32, error) {
+	if token := r.URL.Query().Get("session"); token != "" {
+		br, err := h.service.JoinSession(context.TODO(), bar.JoinSessionDTO{Token: token})
+		if err != nil {
+			return "", 0, err
+		}
+
+		return br.EventID, br.ID, nil
+	}
+
+	eventID := r.URL.Query().Get("event_id")
+	if eventID == "" {
+		return "", 0, fmt.Errorf("param session or event_id is empty")
+	}
+
+	if userID == "" || h.userRole(context.TODO(), userID, eventID, 0) != roleHost {
+		h.logger.Errorf("user %q has no access to event %s", userID, eventID)
+		return "", 0, apperror.ErrForbidden
+	}
+
+	return eventID, 0, nil
+}
+
+// wsError отвечает на запрос подключения, которое не прошло проверку
+func wsError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, apperror.ErrForbidden) {
+		status = http.StatusForbidden
+	}
+
+	http.Error(w, err.Error(), status)
+}
+
+// processMessage выполняет действие проверенного входящего сообщения
+// и рассылает результат в комнату бара
+func (h *handler) processMessage(c *Client, env Envelope) error {
+	switch env.Type {
+	case msgOrderStatusChanged:
+		var p ChangeStatusPayload
+		if err := decodePayload(env, &p); err != nil {
+			return err
+		}
+
+		ordr, err := h.orderService.FindOrder(context.TODO(), order.FindOrderDTO{ID: p.OrderID})
+		if errors.Is(err, apperror.ErrNotFound) {
+			return fmt.Errorf("order %s not found", p.OrderID)
+		} else if err != nil {
+			return err
+		}
+
+		if ordr.EventID != c.eventID || (c.barID != 0 && ordr.BarID != c.barID) {
+			return fmt.Errorf("order %s belongs to another bar", p.OrderID)
+		}
+
+		dto := order.ChangeStatusDTO{
+			ID:     p.OrderID,
+			Status: p.Status,
+			UserID: c.userID,
+		}
+
+		ordr, err = h.orderService.ChangeStatus(context.TODO(), dto)
+		if err != nil {
+			return err
+		}
+
+		h.broadcastOrder(msgOrderStatusChanged, ordr)
+	case msgDrinkAvailability:
+		var p DrinkAvailabilityPayload
+		if err := decodePayload(env, &p); err != nil {
+			return err
+		}
+
+		if p.BarID == 0 {
+			p.BarID = c.barID
+		}
+
+		if p.BarID == 0 || (c.barID != 0 && p.BarID != c.barID) {
+			return fmt.Errorf("wrong bar_id: %d", p.BarID)
+		}
+
+		dto := bar.SetDrinkAvailabilityDTO{
+			BarID:     p.BarID,
+			DrinkID:   p.DrinkID,
+			Available: p.Available,
+			UserID:    c.userID,
+		}
+
+		if _, err := h.setDrinkAvailability(dto, c.eventID); err != nil {
+			return err
+		}
+	case msgResume:
+		var p ResumePayload
+		if err := decodePayload(env, &p); err != nil {
+			return err
+		}
+
+		if p.BarID == 0 {
+			p.BarID = c.barID
+		}
+
+		if p.BarID == 0 || (c.barID != 0 && p.BarID != c.barID) {
+			return fmt.Errorf("wrong bar_id: %d", p.BarID)
+		}
+
+		return h.replay(c, p)
+	case msgAck:
+		// клиент подтвердил получение сообщения, ответ не нужен
+	}
+
+	return nil
+}
+
+// replay переотправляет клиенту сообщения о заказах бара с номером больше p.LastSeq
+// из сохраненной истории заказов и завершает их сообщением resumed.
+// Сообщения, которые клиент получит одновременно вживую, могут повториться -
+// клиент отбрасывает сообщения с уже полученным seq
+func (h *handler) replay(c *Client, p ResumePayload) error {
+	dto := order.FindBarHistoryDTO{
+		BarID:    p.BarID,
+		EventID:  c.eventID,
+		AfterSeq: p.LastSeq,
+		Limit:    replayLimit,
+	}
+
+	events, err := h.orderService.FindBarHistory(context.TODO(), dto)
+	if err != nil {
+		return fmt.Errorf("finding missed messages error: %v", err)
+	}
+
+	resumed := ResumedPayload{
+		BarID:   p.BarID,
+		LastSeq: p.LastSeq,
+		HasMore: len(events) == replayLimit,
+	}
+
+	for _, ev := range events {
+		message, err := historyMessage(ev)
+		if err != nil {
+			return err
+		}
+
+		h.hub.SendToClient(c, message)
+		resumed.LastSeq = ev.Change.Seq
+	}
+
+	c.sendEnvelope(msgResumed, resumed)
+
+	return nil
+}
+
+// setDrinkAvailability сохраняет доступность напитка и сразу рассылает ее в комнату бара.
+// Если eventID не пустой, бар должен относиться к этому ивенту
+func (h *handler) setDrinkAvailability(dto bar.SetDrinkAvailabilityDTO, eventID string) (bar.DrinkAvailability, error) {
+	br, err := h.service.FindBar(context.TODO(), bar.FindBarDTO{ID: dto.BarID})
+	if err != nil {
+		return bar.DrinkAvailability{}, err
+	}
+
+	if eventID != "" && br.EventID != eventID {
+		return bar.DrinkAvailability{}, fmt.Errorf("bar %d belongs to another event", br.ID)
+	}
+
+	availability, err := h.service.SetDrinkAvailability(context.TODO(), dto)
+	if err != nil {
+		return bar.DrinkAvailability{}, err
+	}
+
+	payload := DrinkAvailabilityPayload{
+		BarID:     availability.BarID,
+		DrinkID:   availability.DrinkID,
+		Available: availability.Available,
+	}
+
+	message, err := newEnvelope(msgDrinkAvailability, payload)
+	if err != nil {
+		return bar.DrinkAvailability{}, err
+	}
+
+	h.hub.SendToBar(br.EventID, br.ID, message)
+
+	return availability, nil
+}
+
+// historyMessage восстанавливает сообщение о заказе из записи его истории
+func historyMessage(ev order.HistoryEvent) ([]byte, error) {
+	msgType := msgOrderStatusChanged
+	if ev.Change.From == "" {
+		msgType = msgOrderCreated
+	}
+
+	return newSeqEnvelope(msgType, ev.Change.Seq, roomOrder(ev.Order))
+}
+
+// roomOrder убирает из заказа то, что не должны видеть клиенты комнаты.
+// Комнаты бара и ивента открыты гостям, поэтому счет, на который начислен заказ, не рассылается
+func roomOrder(ordr order.Order) order.Order {
+	ordr.TabID = ""
+	return ordr
+}
+
+// broadcastOrder рассылает заказ в комнату его бара
+func (h *handler) broadcastOrder(msgType string, ordr order.Order) {
+	message, err := newSeqEnvelope(msgType, ordr.LastSeq(), roomOrder(ordr))
+	if err != nil {
+		h.logger.Errorf("encoding %s message error: %v", msgType, err)
+		return
+	}
+
+	h.hub.SendToBar(ordr.EventID, ordr.BarID, message)
+}
+
+func (h *handler) Verify(protectedHandler apperror.AppHandler) apperror.AppHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		cookie, err := r.Cookie("AccessToken")
+		if err != nil {
+			h.logger.Errorf("cookie error: %v", err)
+			return apperror.ErrUnauthorized
+		}
+
+		if cookie.Value == "" {
+			h.logger.Errorf("access token is empty")
+			return apperror.ErrUnauthorized
+		}
+
+		err = h.userService.Verify(context.TODO(), cookie.Value)
+		if err != nil {
+			h.logger.Errorf("access token is wrong: %v", err)
+			return apperror.ErrUnauthorized
+		}
+
+		return protectedHandler(w, r)
+	}
+}
+
+// barRole возвращает роль пользователя запроса в баре:
+// host для организатора ивента бара или роль сотрудника бара.
+// Остальным пользователям доступ к бару запрещен
+func (h *handler) barRole(r *http.Request, barID uint32) (string, error) {
+	userID, err := h.userID(r)
+	if err != nil {
+		return "", err
+	}
+
+	br, err := h.service.FindBar(context.TODO(), bar.FindBarDTO{ID: barID})
+	if err != nil {
+		return "", apperror.NewAppError(err, "wrong bar id", err.Error(), "US-000009")
+	}
+
+	role := h.userRole(context.TODO(), userID, br.EventID, br.ID)
+	if role == roleGuest {
+		h.logger.Errorf("user %s has no access to bar %d", userID, barID)
+		return "", apperror.ErrForbidden
+	}
+
+	return role, nil
+}
+
+// hideSession убирает из бара ссылку и токен сессии, по которым к бару подключаются клиенты
+func hideSession(br *bar.Bar) {
+	br.SessionURL = ""
+	br.SessionToken = ""
+}
+
+// eventHost проверяет, что пользователь запроса - организатор ивента
+func (h *handler) eventHost(r *http.Request, eventID string) error {
+	userID, err := h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	if h.userRole(context.TODO(), userID, eventID, 0) != roleHost {
+		h.logger.Errorf("user %s is not the host of event %s", userID, eventID)
+		return apperror.ErrForbidden
+	}
+
+	return nil
+}
+
+// userRole возвращает роль пользователя в ивенте и его баре barID (0 - весь ивент)
+func (h *handler) userRole(ctx context.Context, userID, eventID string, barID uint32) string {
+	_, err := h.eventService.FindEvent(ctx, event.FindEventDTO{ID: eventID, UserID: userID})
+	if err == nil {
+		return roleHost
+	}
+
+	if barID == 0 {
+		return roleGuest
+	}
+
+	member, err := h.service.FindStaffMember(ctx, bar.FindStaffMemberDTO{BarID: barID, UserID: userID})
+	if err != nil {
+		return roleGuest
+	}
+
+	return member.Role
+}
+
+// Возвращает id пользователя из AccessToken запроса
+func (h *handler) userID(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("AccessToken")
+	if err != nil {
+		h.logger.Errorf("cookie error: %v", err)
+		return "", apperror.ErrUnauthorized
+	}
+
+	userID, err := h.userService.GetUserIDByToken(context.TODO(), cookie.Value)
+	if err != nil {
+		h.logger.Errorf("access token is wrong: %v", err)
+		return "", apperror.ErrUnauthorized
+	}
+
+	return userID, nil
 }
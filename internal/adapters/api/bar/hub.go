@@ -1,51 +1,404 @@
 package bar_api
 
-// Hub maintains the set of active clients and broadcasts messages to the
-// clients.
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// delivery targets
+	toBar = iota
+	toEvent
+	toClient
+)
+
+const (
+	// Сколько сообщений для других экземпляров может ждать публикации.
+	// Если очередь заполнена, сообщение получат только клиенты этого экземпляра
+	outboxSize = 1024
+
+	// Сколько ждать публикации одного сообщения
+	publishTimeout = 5 * time.Second
+
+	// Сколько хаб помнит закрытую сессию. Этого хватает клиенту, прошедшему
+	// JoinSession до закрытия, а позже бар уже закрыт в базе
+	closedSessionTTL = 10 * time.Minute
+)
+
+// Broker пересылает сообщения хаба другим экземплярам приложения.
+// Subscribe блокируется и вызывает handler для каждого опубликованного сообщения,
+// в том числе опубликованного этим же экземпляром
+type Broker interface {
+	Publish(ctx context.Context, data []byte) error
+	Subscribe(ctx context.Context, handler func(data []byte)) error
+}
+
+const (
+	// broadcast kinds
+	broadcastBar          = "bar"
+	broadcastEvent        = "event"
+	broadcastCloseSession = "close_session"
+)
+
+// Сообщение комнате или закрытие сессии бара, разосланное через Broker.
+// Origin - экземпляр, который его опубликовал и уже доставил своим клиентам
+type broadcast struct {
+	Origin  string          `json:"origin"`
+	Kind    string          `json:"kind"`
+	EventID string          `json:"event_id"`
+	BarID   uint32          `json:"bar_id"`
+	Message json.RawMessage `json:"message,omitempty"`
+}
+
+// Открытие или закрытие сессии бара. done закрывается, когда хаб обработал запрос
+type sessionRequest struct {
+	eventID string
+	barID   uint32
+	open    bool
+	done    chan struct{}
+}
+
+// Комнаты ивента, в которых есть клиенты этого экземпляра.
+// watched - к ивенту подключены клиенты без бара, например организатор
+type eventRooms struct {
+	bars    map[uint32]bool
+	watched bool
+}
+
+// Сообщение и тот, кому оно адресовано: все клиенты бара, все клиенты ивента
+// или один клиент
+type delivery struct {
+	target  int
+	barID   uint32
+	eventID string
+	client  *Client
+	message []byte
+}
+
+// Hub maintains the set of active clients and routes messages to the rooms
+// of bars and events.
+//
+// Каждый клиент состоит в комнате своего ивента и, если подключен к бару,
+// в комнате бара. Сообщения ивенту получают и клиенты всех его баров.
+// Если задан broker, сообщения комнатам и закрытие сессий доходят
+// и до клиентов, подключенных к другим экземплярам приложения
 type Hub struct {
+	broker     Broker
+	instanceID string
+
 	// Registered clients.
 	clients map[*Client]bool
 
-	// Inbound messages from the clients.
-	broadcast chan []byte
+	// Комнаты баров и ивентов
+	bars   map[uint32]map[*Client]bool
+	events map[string]map[*Client]bool
+
+	// Бары, сессии которых закрыты, и время закрытия.
+	// Запись удаляется при повторном открытии сессии или через closedSessionTTL
+	closed map[uint32]time.Time
+
+	// Outbound messages to the rooms and clients.
+	deliveries chan delivery
+
+	// Открытие и закрытие сессий баров
+	sessions chan sessionRequest
+
+	// Запросы списка комнат с клиентами
+	rooms chan chan map[string]*eventRooms
 
 	// Register requests from the clients.
 	register chan *Client
 
 	// Unregister requests from clients.
 	unregister chan *Client
+
+	// Сообщения, которые ждут публикации через broker
+	outbox chan []byte
 }
 
-// она была неэкспортируемой!
-func NewHub() *Hub {
+// broker может быть nil, тогда хаб работает только внутри своего экземпляра
+func NewHub(broker Broker) (*Hub, error) {
+	instanceID, err := newMessageID()
+	if err != nil {
+		return nil, fmt.Errorf("generating hub instance id error: %v", err)
+	}
+
 	return &Hub{
-		broadcast:  make(chan []byte),
+		broker:     broker,
+		instanceID: instanceID,
+		outbox:     make(chan []byte, outboxSize),
+		deliveries: make(chan delivery),
+		sessions:   make(chan sessionRequest),
+		rooms:      make(chan chan map[string]*eventRooms),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
-	}
+		bars:       make(map[uint32]map[*Client]bool),
+		events:     make(map[string]map[*Client]bool),
+		closed:     make(map[uint32]time.Time),
+	}, nil
+}
+
+// SendToBar отправляет сообщение всем клиентам бара и клиентам ивента,
+// подключенным ко всему ивенту, а не к отдельному бару (например, организатору)
+func (h *Hub) SendToBar(eventID string, barID uint32, message []byte) {
+	h.deliveries <- delivery{target: toBar, eventID: eventID, barID: barID, message: message}
+	h.publish(broadcast{Kind: broadcastBar, EventID: eventID, BarID: barID, Message: message})
+}
+
+// SendToEvent отправляет сообщение всем клиентам ивента, включая клиентов его баров
+func (h *Hub) SendToEvent(eventID string, message []byte) {
+	h.deliveries <- delivery{target: toEvent, eventID: eventID, message: message}
+	h.publish(broadcast{Kind: broadcastEvent, EventID: eventID, Message: message})
+}
+
+// sendToLocalBar отправляет сообщение в комнату бара только клиентам этого экземпляра
+func (h *Hub) sendToLocalBar(eventID string, barID uint32, message []byte) {
+	h.deliveries <- delivery{target: toBar, eventID: eventID, barID: barID, message: message}
+}
+
+// activeRooms возвращает комнаты ивентов и баров, в которых есть клиенты этого экземпляра
+func (h *Hub) activeRooms() map[string]*eventRooms {
+	reply := make(chan map[string]*eventRooms)
+	h.rooms <- reply
+	return <-reply
+}
+
+// SendToClient отправляет сообщение одному клиенту этого экземпляра
+func (h *Hub) SendToClient(client *Client, message []byte) {
+	h.deliveries <- delivery{target: toClient, client: client, message: message}
 }
 
-// она была неэкспортируемой!
+// OpenSession создает комнату бара, к которой подключаются клиенты по ссылке сессии
+func (h *Hub) OpenSession(eventID string, barID uint32) {
+	h.sessions <- sessionRequest{eventID: eventID, barID: barID, open: true}
+}
+
+// CloseSession отправляет клиентам бара и организаторам ивента сообщение bar.closed
+// и отключает клиентов бара. Сообщения, уже поставленные в очередь клиента,
+// отправляются до закрытия соединения. Возвращается, когда клиенты отключены от хаба
+func (h *Hub) CloseSession(eventID string, barID uint32) {
+	done := make(chan struct{})
+	h.sessions <- sessionRequest{eventID: eventID, barID: barID, done: done}
+	<-done
+
+	h.publish(broadcast{Kind: broadcastCloseSession, EventID: eventID, BarID: barID})
+}
+
+// Run обрабатывает подключения и сообщения всех баров.
+// Запускается один раз при старте приложения
 func (h *Hub) Run() {
+	if h.broker != nil {
+		go h.subscribe()
+		go h.publishLoop()
+	}
+
 	for {
 		select {
 		case client := <-h.register:
-			h.clients[client] = true
+			// Клиент мог пройти JoinSession до того, как сессия бара закрылась
+			if _, ok := h.closed[client.barID]; ok {
+				close(client.send)
+				continue
+			}
+
+			h.addClient(client)
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+				h.removeClient(client)
+			}
+		case req := <-h.sessions:
+			if req.open {
+				delete(h.closed, req.barID)
+				if h.bars[req.barID] == nil {
+					h.bars[req.barID] = make(map[*Client]bool)
+				}
+				continue
+			}
+
+			h.closeSession(req.eventID, req.barID)
+			if req.done != nil {
+				close(req.done)
 			}
-		case message := <-h.broadcast:
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
+		case reply := <-h.rooms:
+			reply <- h.collectRooms()
+		case d := <-h.deliveries:
+			switch d.target {
+			case toBar:
+				h.fanOut(h.bars[d.barID], d.message)
+				for client := range h.events[d.eventID] {
+					if client.barID == 0 {
+						h.deliver(client, d.message)
+					}
+				}
+			case toEvent:
+				h.fanOut(h.events[d.eventID], d.message)
+			case toClient:
+				if _, ok := h.clients[d.client]; ok {
+					h.deliver(d.client, d.message)
 				}
 			}
 		}
 	}
 }
+
+func (h *Hub) collectRooms() map[string]*eventRooms {
+	rooms := make(map[string]*eventRooms, len(h.events))
+
+	for eventID, clients := range h.events {
+		evRooms := &eventRooms{bars: make(map[uint32]bool)}
+
+		for client := range clients {
+			if client.barID == 0 {
+				evRooms.watched = true
+			} else {
+				evRooms.bars[client.barID] = true
+			}
+		}
+
+		rooms[eventID] = evRooms
+	}
+
+	return rooms
+}
+
+// publish ставит сообщение в очередь для других экземпляров. Своим клиентам оно уже доставлено.
+// Не ждет broker, поэтому не задерживает обработчик запроса или клиента
+func (h *Hub) publish(b broadcast) {
+	if h.broker == nil {
+		return
+	}
+
+	b.Origin = h.instanceID
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		log.Printf("error: %v", err)
+		return
+	}
+
+	select {
+	case h.outbox <- data:
+	default:
+		log.Printf("hub outbox is full, %s message to event %s is not published", b.Kind, b.EventID)
+	}
+}
+
+// publishLoop публикует сообщения из очереди по одному, в порядке их отправки
+func (h *Hub) publishLoop() {
+	for data := range h.outbox {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		if err := h.broker.Publish(ctx, data); err != nil {
+			log.Printf("publishing hub message error: %v", err)
+		}
+		cancel()
+	}
+}
+
+// subscribe доставляет своим клиентам сообщения, опубликованные другими экземплярами
+func (h *Hub) subscribe() {
+	err := h.broker.Subscribe(context.Background(), func(data []byte) {
+		var b broadcast
+		if err := json.Unmarshal(data, &b); err != nil {
+			log.Printf("wrong hub message: %v", err)
+			return
+		}
+
+		if b.Origin == h.instanceID {
+			return
+		}
+
+		switch b.Kind {
+		case broadcastBar:
+			h.deliveries <- delivery{target: toBar, eventID: b.EventID, barID: b.BarID, message: b.Message}
+		case broadcastEvent:
+			h.deliveries <- delivery{target: toEvent, eventID: b.EventID, message: b.Message}
+		case broadcastCloseSession:
+			h.sessions <- sessionRequest{eventID: b.EventID, barID: b.BarID}
+		}
+	})
+	if err != nil {
+		log.Printf("hub subscription is stopped: %v", err)
+	}
+}
+
+func (h *Hub) closeSession(eventID string, barID uint32) {
+	message, err := newEnvelope(msgBarClosed, BarClosedPayload{BarID: barID})
+	if err == nil {
+		h.fanOut(h.bars[barID], message)
+		for client := range h.events[eventID] {
+			if client.barID == 0 {
+				h.deliver(client, message)
+			}
+		}
+	}
+
+	// После закрытия канала writePump отправит оставшиеся сообщения
+	// и закроет соединение
+	for client := range h.bars[barID] {
+		h.removeClient(client)
+	}
+	delete(h.bars, barID)
+
+	now := time.Now()
+	for id, closedAt := range h.closed {
+		if now.Sub(closedAt) > closedSessionTTL {
+			delete(h.closed, id)
+		}
+	}
+	h.closed[barID] = now
+}
+
+func (h *Hub) fanOut(room map[*Client]bool, message []byte) {
+	for client := range room {
+		h.deliver(client, message)
+	}
+}
+
+// Клиент, который не успевает читать сообщения, отключается
+func (h *Hub) deliver(client *Client, message []byte) {
+	select {
+	case client.send <- message:
+	default:
+		h.removeClient(client)
+	}
+}
+
+func (h *Hub) addClient(client *Client) {
+	h.clients[client] = true
+
+	if h.events[client.eventID] == nil {
+		h.events[client.eventID] = make(map[*Client]bool)
+	}
+	h.events[client.eventID][client] = true
+
+	if client.barID != 0 {
+		if h.bars[client.barID] == nil {
+			h.bars[client.barID] = make(map[*Client]bool)
+		}
+		h.bars[client.barID][client] = true
+	}
+}
+
+func (h *Hub) removeClient(client *Client) {
+	delete(h.clients, client)
+
+	if room, ok := h.events[client.eventID]; ok {
+		delete(room, client)
+		if len(room) == 0 {
+			delete(h.events, client.eventID)
+		}
+	}
+
+	if room, ok := h.bars[client.barID]; ok {
+		delete(room, client)
+		if len(room) == 0 {
+			delete(h.bars, client.barID)
+		}
+	}
+
+	close(client.send)
+}
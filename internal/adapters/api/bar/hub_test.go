@@ -0,0 +1,140 @@
+package bar_api
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newTestHub(t *testing.T) *Hub {
+	t.Helper()
+
+	h, err := NewHub(nil)
+	if err != nil {
+		t.Fatalf("NewHub: %v", err)
+	}
+	go h.Run()
+
+	return h
+}
+
+// received возвращает имена клиентов, которым пришло сообщение, и очищает их очереди
+func received(clients map[string]*Client) []string {
+	var names []string
+	for name, client := range clients {
+		select {
+		case <-client.send:
+			names = append(names, name)
+		default:
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func TestHubRouting(t *testing.T) {
+	h := newTestHub(t)
+
+	clients := map[string]*Client{
+		"bar1":  {eventID: "e1", barID: 1, send: make(chan []byte, 8)},
+		"bar2":  {eventID: "e1", barID: 2, send: make(chan []byte, 8)},
+		"host":  {eventID: "e1", send: make(chan []byte, 8)},
+		"other": {eventID: "e2", barID: 3, send: make(chan []byte, 8)},
+	}
+	for _, client := range clients {
+		h.register <- client
+	}
+
+	unregistered := &Client{eventID: "e1", barID: 1, send: make(chan []byte, 8)}
+
+	tests := []struct {
+		name string
+		send func()
+		want []string
+	}{
+		{"bar room and event watchers", func() { h.SendToBar("e1", 1, []byte("m")) }, []string{"bar1", "host"}},
+		{"bar of another event", func() { h.SendToBar("e2", 3, []byte("m")) }, []string{"other"}},
+		{"event room with its bars", func() { h.SendToEvent("e1", []byte("m")) }, []string{"bar1", "bar2", "host"}},
+		{"unknown event", func() { h.SendToEvent("e3", []byte("m")) }, nil},
+		{"one client", func() { h.SendToClient(clients["bar2"], []byte("m")) }, []string{"bar2"}},
+		{"unregistered client", func() { h.SendToClient(unregistered, []byte("m")) }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.send()
+			// Хаб обрабатывает запросы по очереди: после ответа сообщение уже доставлено
+			h.activeRooms()
+
+			if got := received(clients); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("received by %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	select {
+	case <-unregistered.send:
+		t.Error("unregistered client received a message")
+	default:
+	}
+}
+
+func TestHubCloseSession(t *testing.T) {
+	h := newTestHub(t)
+
+	bar := &Client{eventID: "e1", barID: 1, send: make(chan []byte, 8)}
+	host := &Client{eventID: "e1", send: make(chan []byte, 8)}
+	h.register <- bar
+	h.register <- host
+
+	h.CloseSession("e1", 1)
+
+	// Оба получают bar.closed, клиент бара отключается
+	if _, ok := <-bar.send; !ok {
+		t.Fatal("bar client did not receive bar.closed")
+	}
+	if _, ok := <-bar.send; ok {
+		t.Error("bar client is not disconnected")
+	}
+	if _, ok := <-host.send; !ok {
+		t.Error("host did not receive bar.closed")
+	}
+
+	late := &Client{eventID: "e1", barID: 1, send: make(chan []byte, 8)}
+	h.register <- late
+	if _, ok := <-late.send; ok {
+		t.Error("client joined a closed session")
+	}
+
+	h.OpenSession("e1", 1)
+	reopened := &Client{eventID: "e1", barID: 1, send: make(chan []byte, 8)}
+	h.register <- reopened
+	if rooms := h.activeRooms(); rooms["e1"] == nil || !rooms["e1"].bars[1] {
+		t.Error("client did not join a reopened session")
+	}
+}
+
+func TestHubClosedSessionsExpire(t *testing.T) {
+	h, err := NewHub(nil)
+	if err != nil {
+		t.Fatalf("NewHub: %v", err)
+	}
+	h.closed[1] = time.Now().Add(-2 * closedSessionTTL)
+	go h.Run()
+
+	// Закрытие другой сессии удаляет устаревшие записи
+	h.CloseSession("e1", 2)
+
+	h.register <- &Client{eventID: "e1", barID: 1, send: make(chan []byte, 8)}
+	h.register <- &Client{eventID: "e1", barID: 2, send: make(chan []byte, 8)}
+
+	rooms := h.activeRooms()
+	if rooms["e1"] == nil || !rooms["e1"].bars[1] {
+		t.Fatal("expired closed session still rejects clients")
+	}
+	if rooms["e1"].bars[2] {
+		t.Error("client joined a closed session")
+	}
+}
@@ -0,0 +1,183 @@
+package bar_api
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Версия протокола websocket-сообщений
+const protocolVersion = 1
+
+const (
+	// message types
+	msgOrderCreated       = "order.created"
+	msgOrderStatusChanged = "order.status_changed"
+	msgDrinkAvailability  = "drink.availability"
+	msgBarClosed          = "bar.closed"
+	msgBarStats           = "bar.stats"
+	msgResume             = "resume"
+	msgResumed            = "resumed"
+	msgAck                = "ack"
+	msgError              = "error"
+)
+
+// Envelope - конверт любого websocket-сообщения.
+// ID генерирует отправитель, сервер отвечает на входящие сообщения ack или error с этим ID.
+// Seq есть у сообщений о заказах - это номер записи в истории заказов бара.
+// Клиент запоминает последний полученный Seq бара и после переподключения
+// отправляет его в resume, чтобы получить пропущенные сообщения
+type Envelope struct {
+	Type      string          `json:"type"`
+	Version   int             `json:"version"`
+	ID        string          `json:"id"`
+	Seq       uint64          `json:"seq,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+type AckPayload struct {
+	MessageID string `json:"message_id"`
+}
+
+type ErrorPayload struct {
+	MessageID string `json:"message_id,omitempty"`
+	Message   string `json:"message"`
+}
+
+// Входящее сообщение order.status_changed. Исходящее содержит заказ целиком
+type ChangeStatusPayload struct {
+	OrderID string `json:"order_id"`
+	Status  string `json:"status"`
+}
+
+type DrinkAvailabilityPayload struct {
+	BarID     uint32 `json:"bar_id"`
+	DrinkID   string `json:"drink_id"`
+	Available bool   `json:"available"`
+}
+
+// Бар закрыт, после этого сообщения клиенты бара отключаются
+type BarClosedPayload struct {
+	BarID uint32 `json:"bar_id"`
+}
+
+// Входящее сообщение resume. BarID можно не указывать, если клиент подключен к бару
+type ResumePayload struct {
+	BarID   uint32 `json:"bar_id"`
+	LastSeq uint64 `json:"last_seq"`
+}
+
+// Ответ на resume после переотправки пропущенных сообщений.
+// Если HasMore, клиент повторяет resume с новым LastSeq
+type ResumedPayload struct {
+	BarID   uint32 `json:"bar_id"`
+	LastSeq uint64 `json:"last_seq"`
+	HasMore bool   `json:"has_more"`
+}
+
+// newEnvelope упаковывает payload в конверт и возвращает готовое к отправке сообщение
+func newEnvelope(msgType string, payload any) ([]byte, error) {
+	return newSeqEnvelope(msgType, 0, payload)
+}
+
+// newSeqEnvelope упаковывает payload в конверт с номером записи истории бара
+func newSeqEnvelope(msgType string, seq uint64, payload any) ([]byte, error) {
+	id, err := newMessageID()
+	if err != nil {
+		return nil, err
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(Envelope{
+		Type:      msgType,
+		Version:   protocolVersion,
+		ID:        id,
+		Seq:       seq,
+		Timestamp: time.Now().UTC(),
+		Payload:   payloadBytes,
+	})
+}
+
+// decodeEnvelope разбирает входящее сообщение и проверяет конверт и payload
+func decodeEnvelope(data []byte) (Envelope, error) {
+	var env Envelope
+
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, fmt.Errorf("message is not a valid json envelope: %v", err)
+	}
+
+	if env.Version != protocolVersion {
+		return env, fmt.Errorf("unsupported protocol version %d, expected %d", env.Version, protocolVersion)
+	}
+
+	if env.ID == "" {
+		return env, fmt.Errorf("message id is empty")
+	}
+
+	switch env.Type {
+	case msgAck:
+		var p AckPayload
+		if err := decodePayload(env, &p); err != nil {
+			return env, err
+		}
+		if p.MessageID == "" {
+			return env, fmt.Errorf("ack message_id is empty")
+		}
+	case msgOrderStatusChanged:
+		var p ChangeStatusPayload
+		if err := decodePayload(env, &p); err != nil {
+			return env, err
+		}
+		if p.OrderID == "" || p.Status == "" {
+			return env, fmt.Errorf("order_id and status are required")
+		}
+	case msgDrinkAvailability:
+		var p DrinkAvailabilityPayload
+		if err := decodePayload(env, &p); err != nil {
+			return env, err
+		}
+		if p.DrinkID == "" {
+			return env, fmt.Errorf("drink_id is required")
+		}
+	case msgResume:
+		var p ResumePayload
+		if err := decodePayload(env, &p); err != nil {
+			return env, err
+		}
+	case msgOrderCreated, msgBarClosed, msgBarStats, msgResumed, msgError:
+		return env, fmt.Errorf("message type %s is sent only by server", env.Type)
+	default:
+		return env, fmt.Errorf("unknown message type: %s", env.Type)
+	}
+
+	return env, nil
+}
+
+func decodePayload(env Envelope, v any) error {
+	if len(env.Payload) == 0 {
+		return fmt.Errorf("%s payload is empty", env.Type)
+	}
+
+	if err := json.Unmarshal(env.Payload, v); err != nil {
+		return fmt.Errorf("wrong %s payload: %v", env.Type, err)
+	}
+
+	return nil
+}
+
+func newMessageID() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", b), nil
+}
@@ -0,0 +1,57 @@
+package bar_api
+
+import "testing"
+
+func TestDecodeEnvelope(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"ack", `{"type":"ack","version":1,"id":"1","payload":{"message_id":"2"}}`, false},
+		{"status changed", `{"type":"order.status_changed","version":1,"id":"1","payload":{"order_id":"o","status":"Ready"}}`, false},
+		{"drink availability", `{"type":"drink.availability","version":1,"id":"1","payload":{"drink_id":"d","available":false}}`, false},
+		{"resume without bar", `{"type":"resume","version":1,"id":"1","payload":{"last_seq":10}}`, false},
+		{"not json", `type=ack`, true},
+		{"wrong version", `{"type":"ack","version":2,"id":"1","payload":{"message_id":"2"}}`, true},
+		{"no version", `{"type":"ack","id":"1","payload":{"message_id":"2"}}`, true},
+		{"empty id", `{"type":"ack","version":1,"payload":{"message_id":"2"}}`, true},
+		{"empty payload", `{"type":"ack","version":1,"id":"1"}`, true},
+		{"wrong payload", `{"type":"ack","version":1,"id":"1","payload":{"message_id":2}}`, true},
+		{"ack without message id", `{"type":"ack","version":1,"id":"1","payload":{}}`, true},
+		{"status without order", `{"type":"order.status_changed","version":1,"id":"1","payload":{"status":"Ready"}}`, true},
+		{"status without status", `{"type":"order.status_changed","version":1,"id":"1","payload":{"order_id":"o"}}`, true},
+		{"availability without drink", `{"type":"drink.availability","version":1,"id":"1","payload":{"available":true}}`, true},
+		{"server message", `{"type":"order.created","version":1,"id":"1","payload":{}}`, true},
+		{"unknown type", `{"type":"order.deleted","version":1,"id":"1","payload":{}}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := decodeEnvelope([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeEnvelope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err == nil && env.ID != "1" {
+				t.Errorf("decodeEnvelope() id = %q, want 1", env.ID)
+			}
+		})
+	}
+}
+
+func TestNewEnvelopeDecodes(t *testing.T) {
+	data, err := newEnvelope(msgAck, AckPayload{MessageID: "2"})
+	if err != nil {
+		t.Fatalf("newEnvelope: %v", err)
+	}
+
+	env, err := decodeEnvelope(data)
+	if err != nil {
+		t.Fatalf("decodeEnvelope: %v", err)
+	}
+
+	if env.Type != msgAck || env.ID == "" || env.Timestamp.IsZero() {
+		t.Errorf("decodeEnvelope() = %+v", env)
+	}
+}
@@ -0,0 +1,191 @@
+package bar_api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"restapi/internal/apperror"
+	"restapi/internal/domain/bar"
+	"restapi/internal/domain/event"
+	"restapi/pkg/qrcode"
+	"strconv"
+)
+
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 2048
+
+	// Размер QR-кода на печатной карточке
+	cardQRSize = 220
+)
+
+// Печатная страница с карточкой для каждого открытого бара ивента
+var cardsTemplate = template.Must(template.New("cards").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.EventName}}</title>
+<style>
+	body { font-family: sans-serif; margin: 0; }
+	.cards { display: flex; flex-wrap: wrap; }
+	.card { width: 90mm; height: 120mm; box-sizing: border-box; padding: 8mm; border: 1px dashed #999;
+		text-align: center; page-break-inside: avoid; }
+	.card h2 { margin: 0 0 4mm; }
+	.card p { margin: 4mm 0 0; font-size: 12px; }
+	.card .url { word-break: break-all; color: #666; font-size: 9px; }
+</style>
+</head>
+<body>
+<div class="cards">
+{{range .Cards}}
+	<div class="card">
+		<h2>{{.Name}}</h2>
+		{{.QR}}
+		<p>{{.Description}}</p>
+		<p class="url">{{.URL}}</p>
+	</div>
+{{end}}
+</div>
+</body>
+</html>
+`))
+
+type barCard struct {
+	Name        string
+	Description string
+	URL         string
+	QR          template.HTML
+}
+
+// Query params: bar_id, format (png или svg, по умолчанию png) и size - сторона картинки в пикселях
+func (h *handler) GetBarQR(w http.ResponseWriter, r *http.Request) error {
+	var dto bar.FindBarDTO
+
+	id, err := strconv.Atoi(r.URL.Query().Get("bar_id"))
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	dto.ID = uint32(id)
+
+	size := defaultQRSize
+	if param := r.URL.Query().Get("size"); param != "" {
+		size, err = strconv.Atoi(param)
+		if err != nil || size < minQRSize || size > maxQRSize {
+			return apperror.NewAppError(err, "wrong data", fmt.Sprintf("size must be from %d to %d", minQRSize, maxQRSize), "US-000009")
+		}
+	}
+
+	if _, err := h.barRole(r, dto.ID); err != nil {
+		return err
+	}
+
+	br, err := h.service.FindBar(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	if br.Status != bar.StatusOpened {
+		return apperror.NewAppError(nil, "bar is closed", fmt.Sprintf("bar %d is closed", br.ID), "US-000009")
+	}
+
+	var (
+		image       []byte
+		contentType string
+	)
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "png":
+		image, err = qrcode.PNG(h.joinLink(br), size)
+		contentType = "image/png"
+	case "svg":
+		image, err = qrcode.SVG(h.joinLink(br), size)
+		contentType = "image/svg+xml"
+	default:
+		return apperror.NewAppError(nil, "wrong data", fmt.Sprintf("unknown qr format: %s", format), "US-000009")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+
+	return nil
+}
+
+// Страница для печати карточек открытых баров ивента. Доступна только организатору
+func (h *handler) GetBarCards(w http.ResponseWriter, r *http.Request) error {
+	eventID := r.URL.Query().Get("event_id")
+	if eventID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	userID, err := h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	evnt, err := h.eventService.FindEvent(context.TODO(), event.FindEventDTO{ID: eventID, UserID: userID})
+	if err != nil {
+		return apperror.ErrForbidden
+	}
+
+	bars, err := h.service.FindEventBars(context.TODO(), bar.FindEventBarsDTO{EventID: eventID, Status: bar.StatusOpened})
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	cards := make([]barCard, 0, len(bars))
+
+	for _, br := range bars {
+		link := h.joinLink(br)
+
+		svg, err := qrcode.SVG(link, cardQRSize)
+		if err != nil {
+			return err
+		}
+
+		cards = append(cards, barCard{
+			Name:        br.Name,
+			Description: br.Description,
+			URL:         link,
+			QR:          template.HTML(svg),
+		})
+	}
+
+	var page bytes.Buffer
+
+	err = cardsTemplate.Execute(&page, struct {
+		EventName string
+		Cards     []barCard
+	}{
+		EventName: evnt.Name,
+		Cards:     cards,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page.Bytes())
+
+	return nil
+}
+
+// joinLink - ссылка, которую открывает гость, отсканировав QR-код:
+// страница заказа с токеном сессии бара или, если она не настроена, сама ссылка сессии
+func (h *handler) joinLink(br bar.Bar) string {
+	if h.guestPageURL == "" {
+		return br.SessionURL
+	}
+
+	return fmt.Sprintf("%s?session=%s", h.guestPageURL, url.QueryEscape(br.SessionToken))
+}
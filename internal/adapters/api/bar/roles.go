@@ -0,0 +1,54 @@
+package bar_api
+
+import "restapi/internal/domain/bar"
+
+const (
+	// client roles. Роли сотрудников бара совпадают с bar.Staff.Role
+	roleHost          = "host"
+	roleHeadBartender = bar.StaffHeadBartender
+	roleBartender     = bar.StaffBartender
+	roleBarback       = bar.StaffBarback
+	roleGuest         = "guest"
+)
+
+// Какие типы сообщений может отправлять клиент с данной ролью.
+// Барбэк не меняет статусы заказов, но отмечает, каких напитков нет.
+// Гости только получают сообщения, подтверждают их и запрашивают пропущенные
+var allowedMessages = map[string]map[string]bool{
+	roleHost: {
+		msgOrderStatusChanged: true,
+		msgDrinkAvailability:  true,
+		msgResume:             true,
+		msgAck:                true,
+	},
+	roleHeadBartender: {
+		msgOrderStatusChanged: true,
+		msgDrinkAvailability:  true,
+		msgResume:             true,
+		msgAck:                true,
+	},
+	roleBartender: {
+		msgOrderStatusChanged: true,
+		msgDrinkAvailability:  true,
+		msgResume:             true,
+		msgAck:                true,
+	},
+	roleBarback: {
+		msgDrinkAvailability: true,
+		msgResume:            true,
+		msgAck:               true,
+	},
+	roleGuest: {
+		msgResume: true,
+		msgAck:    true,
+	},
+}
+
+func canSend(role, msgType string) bool {
+	return allowedMessages[role][msgType]
+}
+
+// Может ли роль менять статусы заказов через REST API
+func canChangeStatus(role string) bool {
+	return canSend(role, msgOrderStatusChanged)
+}
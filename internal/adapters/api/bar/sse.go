@@ -0,0 +1,248 @@
+package bar_api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"restapi/internal/apperror"
+	"restapi/internal/domain/order"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Через сколько миллисекунд браузер переподключается к потоку после обрыва
+const sseRetryMillis = 3000
+
+// sseCursor - последние отправленные клиенту номера записей истории заказов по барам.
+// Отправляется как id SSE-события в виде "bar_id:seq,bar_id:seq",
+// браузер возвращает его в заголовке Last-Event-ID при переподключении
+type sseCursor map[uint32]uint64
+
+func parseSSECursor(value string) (sseCursor, error) {
+	cursor := make(sseCursor)
+
+	if value == "" {
+		return cursor, nil
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		barPart, seqPart, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("wrong Last-Event-ID: %s", value)
+		}
+
+		barID, err := strconv.ParseUint(barPart, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("wrong Last-Event-ID bar_id: %v", err)
+		}
+
+		seq, err := strconv.ParseUint(seqPart, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("wrong Last-Event-ID seq: %v", err)
+		}
+
+		cursor[uint32(barID)] = seq
+	}
+
+	return cursor, nil
+}
+
+func (c sseCursor) String() string {
+	barIDs := make([]uint32, 0, len(c))
+	for barID := range c {
+		barIDs = append(barIDs, barID)
+	}
+
+	sort.Slice(barIDs, func(i, j int) bool { return barIDs[i] < barIDs[j] })
+
+	parts := make([]string, 0, len(barIDs))
+	for _, barID := range barIDs {
+		parts = append(parts, fmt.Sprintf("%d:%d", barID, c[barID]))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// ServeSSE отдает те же сообщения, что и websocket, потоком text/event-stream
+// для клиентов, которые не могут подключиться по websocket.
+// Клиент подключается к хабу так же, как websocket-клиент, но только получает сообщения.
+// Если передан Last-Event-ID, сначала переотправляются пропущенные сообщения о заказах
+func (h *handler) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.wsUserID(r)
+	if err != nil {
+		h.logger.Errorf("sse access token is wrong: %v", err)
+		http.Error(w, apperror.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	eventID, barID, err := h.wsRooms(r, userID)
+	if err != nil {
+		wsError(w, err)
+		return
+	}
+
+	cursor, err := parseSSECursor(r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Клиент бара получает пропущенные сообщения только своего бара
+	if barID != 0 {
+		seq, ok := cursor[barID]
+		cursor = make(sseCursor)
+		if ok {
+			cursor[barID] = seq
+		}
+	}
+
+	rc := http.NewResponseController(w)
+
+	// Поток живет дольше WriteTimeout сервера
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Errorf("sse write deadline error: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis)
+
+	client := &Client{
+		hub:     h.hub,
+		send:    make(chan []byte, 256),
+		eventID: eventID,
+		barID:   barID,
+		role:    roleGuest,
+	}
+
+	// Клиент подключается к хабу до переотправки, чтобы не пропустить новые сообщения
+	h.hub.register <- client
+	defer func() {
+		h.hub.unregister <- client
+	}()
+
+	h.logger.Infof("sse client connected: event_id: %s, bar_id: %d", eventID, barID)
+
+	replayed, err := h.replaySSE(w, eventID, cursor)
+	if err != nil {
+		h.logger.Errorf("sse replay error: %v", err)
+		return
+	}
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case message, ok := <-client.send:
+			if !ok {
+				// Хаб отключил клиента, например, бар закрыт
+				return
+			}
+
+			if err := writeSSE(w, message, cursor, replayed); err != nil {
+				return
+			}
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// replaySSE отправляет сообщения о заказах баров из cursor, сделанные после сохраненных номеров.
+// Возвращает последние переотправленные номера, чтобы не повторять эти сообщения из хаба
+func (h *handler) replaySSE(w http.ResponseWriter, eventID string, cursor sseCursor) (sseCursor, error) {
+	replayed := make(sseCursor, len(cursor))
+
+	barIDs := make([]uint32, 0, len(cursor))
+	for barID := range cursor {
+		barIDs = append(barIDs, barID)
+	}
+
+	for _, barID := range barIDs {
+		for {
+			dto := order.FindBarHistoryDTO{
+				BarID:    barID,
+				EventID:  eventID,
+				AfterSeq: cursor[barID],
+				Limit:    replayLimit,
+			}
+
+			events, err := h.orderService.FindBarHistory(context.TODO(), dto)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, ev := range events {
+				message, err := historyMessage(ev)
+				if err != nil {
+					return nil, err
+				}
+
+				if err := writeSSE(w, message, cursor, replayed); err != nil {
+					return nil, err
+				}
+			}
+
+			replayed[barID] = cursor[barID]
+
+			if len(events) < replayLimit {
+				break
+			}
+		}
+	}
+
+	return replayed, nil
+}
+
+// writeSSE записывает сообщение как SSE-событие с типом сообщения.
+// Сообщения о заказах получают id с курсором клиента.
+// Сообщение, уже отправленное при переотправке, пропускается
+func writeSSE(w http.ResponseWriter, message []byte, cursor, replayed sseCursor) error {
+	var env Envelope
+	if err := json.Unmarshal(message, &env); err != nil {
+		return err
+	}
+
+	if env.Seq != 0 {
+		var p struct {
+			BarID uint32 `json:"bar_id"`
+		}
+
+		if err := json.Unmarshal(env.Payload, &p); err != nil {
+			return err
+		}
+
+		if env.Seq <= replayed[p.BarID] {
+			return nil
+		}
+
+		if env.Seq > cursor[p.BarID] {
+			cursor[p.BarID] = env.Seq
+		}
+
+		if _, err := fmt.Fprintf(w, "id: %s\n", cursor); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", env.Type, message)
+	return err
+}
@@ -0,0 +1,106 @@
+package bar_api
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSSECursor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    sseCursor
+		wantErr bool
+	}{
+		{"", sseCursor{}, false},
+		{"1:10", sseCursor{1: 10}, false},
+		{"1:10,2:0,3:18446744073709551615", sseCursor{1: 10, 2: 0, 3: 18446744073709551615}, false},
+		{"1:5,1:7", sseCursor{1: 7}, false},
+		{"1", nil, true},
+		{"1:10,", nil, true},
+		{"bar:10", nil, true},
+		{"-1:10", nil, true},
+		{"4294967296:10", nil, true},
+		{"1:seq", nil, true},
+		{"1:-10", nil, true},
+		{"1:10;2:3", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSSECursor(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSSECursor(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSSECursor(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSSECursorString(t *testing.T) {
+	cursor := sseCursor{12: 3, 2: 40, 7: 0}
+
+	s := cursor.String()
+	if s != "2:40,7:0,12:3" {
+		t.Errorf("String() = %s, want 2:40,7:0,12:3", s)
+	}
+
+	parsed, err := parseSSECursor(s)
+	if err != nil {
+		t.Fatalf("parseSSECursor(%q): %v", s, err)
+	}
+
+	if !reflect.DeepEqual(parsed, cursor) {
+		t.Errorf("parseSSECursor(String()) = %v, want %v", parsed, cursor)
+	}
+}
+
+func TestWriteSSE(t *testing.T) {
+	order, err := newSeqEnvelope(msgOrderCreated, 5, map[string]uint32{"bar_id": 1})
+	if err != nil {
+		t.Fatalf("newSeqEnvelope: %v", err)
+	}
+
+	stats, err := newEnvelope(msgBarStats, map[string]uint32{"bar_id": 1})
+	if err != nil {
+		t.Fatalf("newEnvelope: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		message  []byte
+		replayed sseCursor
+		wantID   string
+		wantSkip bool
+	}{
+		{"order message moves the cursor", order, sseCursor{}, "id: 1:5,2:3\n", false},
+		{"replayed order message is skipped", order, sseCursor{1: 5}, "", true},
+		{"message without seq has no id", stats, sseCursor{}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			cursor := sseCursor{1: 4, 2: 3}
+
+			if err := writeSSE(w, tt.message, cursor, tt.replayed); err != nil {
+				t.Fatalf("writeSSE: %v", err)
+			}
+
+			body := w.Body.String()
+			if tt.wantSkip {
+				if body != "" {
+					t.Errorf("writeSSE() wrote %q, want nothing", body)
+				}
+				return
+			}
+
+			if !strings.HasPrefix(body, tt.wantID+"event: ") {
+				t.Errorf("writeSSE() = %q, want id %q", body, tt.wantID)
+			}
+		})
+	}
+}
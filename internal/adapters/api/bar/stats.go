@@ -0,0 +1,150 @@
+package bar_api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"restapi/internal/apperror"
+	"restapi/internal/domain/bar"
+	"restapi/internal/domain/order"
+	"restapi/pkg/logging"
+	"strconv"
+	"time"
+)
+
+const (
+	// Как часто клиенты баров получают bar.stats
+	statsPushPeriod = 30 * time.Second
+
+	// За какой период по умолчанию считаются заказы в минуту и время подачи
+	defaultStatsWindow = 15 * time.Minute
+
+	// Сколько самых долгих открытых заказов попадает в статистику
+	statsSlowest = 5
+)
+
+// Query param minutes - период статистики в минутах, по умолчанию 15
+func (h *handler) GetBarStats(w http.ResponseWriter, r *http.Request) error {
+	var dto order.FindThroughputDTO
+
+	id, err := strconv.Atoi(r.URL.Query().Get("bar_id"))
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	dto.BarID = uint32(id)
+	dto.EventID = r.URL.Query().Get("event_id")
+	dto.Window = defaultStatsWindow
+	dto.Slowest = statsSlowest
+
+	if dto.EventID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	if m := r.URL.Query().Get("minutes"); m != "" {
+		minutes, err := strconv.Atoi(m)
+		if err != nil {
+			return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+		}
+		dto.Window = time.Duration(minutes) * time.Minute
+	}
+
+	if _, err := h.barRole(r, dto.BarID); err != nil {
+		return err
+	}
+
+	stats, err := h.orderService.GetThroughput(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong data", err.Error(), "US-000009")
+	}
+
+	statsBytes, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(statsBytes)
+
+	return nil
+}
+
+// StatsPusher периодически отправляет статистику баров, к которым подключены клиенты
+// этого экземпляра. Организаторы, подключенные ко всему ивенту, получают статистику
+// всех открытых баров ивента
+type StatsPusher struct {
+	hub          *Hub
+	service      bar.Service
+	orderService order.Service
+	logger       *logging.Logger
+}
+
+func NewStatsPusher(hub *Hub, service bar.Service, orderService order.Service, logger *logging.Logger) *StatsPusher {
+	return &StatsPusher{
+		hub:          hub,
+		service:      service,
+		orderService: orderService,
+		logger:       logger,
+	}
+}
+
+// Run отправляет статистику, пока не отменен ctx
+func (p *StatsPusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(statsPushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.push(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *StatsPusher) push(ctx context.Context) {
+	for eventID, rooms := range p.hub.activeRooms() {
+		if rooms.watched {
+			bars, err := p.service.FindEventBars(ctx, bar.FindEventBarsDTO{EventID: eventID, Status: bar.StatusOpened})
+			if err != nil {
+				p.logger.Errorf("finding event bars for stats error: %v", err)
+			}
+
+			for _, br := range bars {
+				rooms.bars[br.ID] = true
+			}
+		}
+
+		for barID := range rooms.bars {
+			if ctx.Err() != nil {
+				return
+			}
+
+			p.pushBar(ctx, eventID, barID)
+		}
+	}
+}
+
+func (p *StatsPusher) pushBar(ctx context.Context, eventID string, barID uint32) {
+	dto := order.FindThroughputDTO{
+		BarID:   barID,
+		EventID: eventID,
+		Window:  defaultStatsWindow,
+		Slowest: statsSlowest,
+	}
+
+	stats, err := p.orderService.GetThroughput(ctx, dto)
+	if err != nil {
+		p.logger.Errorf("computing bar %d stats error: %v", barID, err)
+		return
+	}
+
+	message, err := newEnvelope(msgBarStats, stats)
+	if err != nil {
+		p.logger.Errorf("encoding %s message error: %v", msgBarStats, err)
+		return
+	}
+
+	p.hub.sendToLocalBar(eventID, barID, message)
+}
@@ -0,0 +1,316 @@
+package event_api
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"restapi/internal/apperror"
+	"restapi/internal/domain/event"
+	"restapi/internal/domain/menu"
+	"restapi/internal/domain/order"
+	"restapi/internal/domain/report"
+	"restapi/pkg/logging"
+	"restapi/pkg/xlsx"
+	"strings"
+	"time"
+)
+
+const (
+	exportEventURL = "/api/event/export"
+
+	// export formats
+	formatCSV  = "csv"
+	formatXLSX = "xlsx"
+
+	// Разделы выгрузки: в XLSX - листы книги, в CSV - отдельные файлы
+	sectionOrders      = "orders"
+	sectionBars        = "bars"
+	sectionDrinks      = "drinks"
+	sectionIngredients = "ingredients"
+
+	// Символы, с которых табличные редакторы начинают формулу.
+	// Табуляция и перевод каретки в начале ячейки тоже приводят к разбору формулы
+	formulaPrefixes = "=+-@\t\r"
+
+	// Сервер ограничивает запись ответа WriteTimeout. Большая выгрузка пишется дольше,
+	// поэтому срок записи продлевается на exportWriteTimeout после каждых exportBatchSize заказов
+	exportBatchSize    = 500
+	exportWriteTimeout = 30 * time.Second
+)
+
+var sections = []string{sectionOrders, sectionBars, sectionDrinks, sectionIngredients}
+
+// Строки раздела пишутся по одной и в CSV, и в лист XLSX
+type rowWriter interface {
+	WriteRow(cells ...any) error
+}
+
+type csvRows struct {
+	w *csv.Writer
+}
+
+func (c csvRows) WriteRow(cells ...any) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case string:
+			record[i] = escapeFormula(v)
+		case time.Time:
+			record[i] = v.Format(time.RFC3339)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+
+	return c.w.Write(record)
+}
+
+// ExportEvent выгружает заказы и отчет ивента его организатору в CSV (один раздел)
+// или XLSX (все разделы). Файл пишется в ответ по мере чтения заказов из базы,
+// поэтому после начала выгрузки ошибки только логируются
+func (h *handler) ExportEvent(w http.ResponseWriter, r *http.Request) error {
+	dto := event.FindEventDTO{
+		ID: r.URL.Query().Get("event_id"),
+	}
+
+	if dto.ID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	userID, err := h.userID(r)
+	if err != nil {
+		return err
+	}
+	dto.UserID = userID
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatXLSX
+	}
+
+	if format != formatCSV && format != formatXLSX {
+		err := fmt.Errorf("unknown export format: %s", format)
+		return apperror.NewAppError(err, "wrong format", err.Error(), "US-000009")
+	}
+
+	section := r.URL.Query().Get("section")
+	if section == "" {
+		section = sectionOrders
+	}
+
+	if !isSection(section) {
+		err := fmt.Errorf("unknown export section: %s", section)
+		return apperror.NewAppError(err, "wrong section", err.Error(), "US-000009")
+	}
+
+	ctx := r.Context()
+
+	evnt, err := h.service.FindEvent(ctx, dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	// Для заказов отчет не нужен, их можно выгрузить и до завершения ивента
+	if (format == formatXLSX || section != sectionOrders) && evnt.Report == nil {
+		err := fmt.Errorf("event %s has no report, its status is %s", evnt.ID, evnt.Status)
+		return apperror.NewAppError(err, "no report", err.Error(), "US-000009")
+	}
+
+	loc, err := event.LoadLocation(evnt.Timezone)
+	if err != nil {
+		return err
+	}
+
+	mn, err := h.service.FindEventMenu(ctx, evnt)
+	if err != nil {
+		return err
+	}
+
+	exp := exporter{
+		ctx:     ctx,
+		service: h.service,
+		event:   evnt,
+		drinks:  mn.DrinksByID(),
+		loc:     loc,
+		rc:      http.NewResponseController(w),
+		logger:  h.logger,
+	}
+	exp.extendDeadline()
+
+	if format == formatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="event-%s-%s.csv"`, evnt.ID, section))
+		w.WriteHeader(http.StatusOK)
+
+		cw := csv.NewWriter(w)
+		err = exp.writeSection(csvRows{w: cw}, section)
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%s.xlsx"`, evnt.ID))
+		w.WriteHeader(http.StatusOK)
+
+		xw := xlsx.NewWriter(w)
+		for _, section := range sections {
+			if err = xw.NewSheet(section); err != nil {
+				break
+			}
+
+			if err = exp.writeSection(xw, section); err != nil {
+				break
+			}
+		}
+
+		if closeErr := xw.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		h.logger.Errorf("event %s export error: %v", evnt.ID, err)
+	}
+
+	return nil
+}
+
+type exporter struct {
+	ctx     context.Context
+	service event.Service
+	event   event.Event
+	drinks  map[string]menu.Drink
+	loc     *time.Location
+	rc      *http.ResponseController
+	logger  *logging.Logger
+}
+
+// extendDeadline продлевает срок записи ответа на exportWriteTimeout от текущего момента
+func (e exporter) extendDeadline() {
+	if err := e.rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil {
+		e.logger.Errorf("export write deadline error: %v", err)
+	}
+}
+
+func (e exporter) writeSection(rw rowWriter, section string) error {
+	switch section {
+	case sectionOrders:
+		return e.writeOrders(rw)
+	case sectionBars:
+		return writeBars(rw, e.event.Report)
+	case sectionDrinks:
+		return writeDrinks(rw, e.event.Report)
+	case sectionIngredients:
+		return writeIngredients(rw, e.event.Report)
+	}
+
+	return fmt.Errorf("unknown export section: %s", section)
+}
+
+// Каждая позиция заказа - отдельная строка, поля заказа повторяются
+func (e exporter) writeOrders(rw rowWriter) error {
+	err := rw.WriteRow("order_id", "bar_id", "tab_id", "status", "created_at", "served_at",
+		"drink_id", "drink", "category", "quantity", "ice_type", "price", "amount", "order_total", "comment")
+	if err != nil {
+		return err
+	}
+
+	written := 0
+
+	return e.service.EachOrder(e.ctx, e.event.ID, func(ordr order.Order) error {
+		written++
+		if written%exportBatchSize == 0 {
+			e.extendDeadline()
+		}
+
+		servedAt := ""
+		if at, ok := ordr.ServedAt(); ok {
+			servedAt = at.In(e.loc).Format(time.RFC3339)
+		}
+
+		for _, item := range ordr.Items {
+			drink := e.drinks[item.DrinkID]
+			name, category, price := order.ItemDrink(item, drink)
+
+			iceType := item.IceType
+			if iceType == "" {
+				iceType = drink.OrderIceType
+			}
+
+			err := rw.WriteRow(ordr.ID, ordr.BarID, ordr.TabID, ordr.Status, ordr.CreatedAt.In(e.loc), servedAt,
+				item.DrinkID, name, category, item.Quantity, iceType, price,
+				price*item.Quantity, ordr.Total, ordr.Comment)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func writeBars(rw rowWriter, rep *report.Report) error {
+	if err := rw.WriteRow("bar_id", "total_orders", "served_orders", "revenue"); err != nil {
+		return err
+	}
+
+	for _, bar := range rep.Bars {
+		if err := rw.WriteRow(bar.BarID, bar.TotalOrders, bar.ServedOrders, bar.Revenue); err != nil {
+			return err
+		}
+	}
+
+	return rw.WriteRow("total", rep.TotalOrders, rep.ServedOrders, rep.Revenue)
+}
+
+func writeDrinks(rw rowWriter, rep *report.Report) error {
+	if err := rw.WriteRow("rank", "drink_id", "drink", "category", "quantity", "revenue"); err != nil {
+		return err
+	}
+
+	for i, drink := range rep.TopDrinks {
+		err := rw.WriteRow(i+1, drink.DrinkID, drink.Name, drink.Category, drink.Quantity, drink.Revenue)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeIngredients(rw rowWriter, rep *report.Report) error {
+	if err := rw.WriteRow("type", "name", "unit", "amount", "cost", "unpriced"); err != nil {
+		return err
+	}
+
+	for _, ingr := range rep.Ingredients {
+		if err := rw.WriteRow(ingr.Type, ingr.Name, ingr.Unit, ingr.Amount, ingr.Cost, ingr.Unpriced); err != nil {
+			return err
+		}
+	}
+
+	return rw.WriteRow("total", "", "", "", rep.IngredientsCost, rep.UnpricedIngredients)
+}
+
+// escapeFormula экранирует текст, который табличный редактор принял бы за формулу,
+// например комментарий гостя или название напитка
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+
+	return s
+}
+
+func isSection(section string) bool {
+	for _, s := range sections {
+		if s == section {
+			return true
+		}
+	}
+
+	return false
+}
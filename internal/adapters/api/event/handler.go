@@ -24,9 +24,14 @@ const (
 	getEventsByHostURL = "/api/user/events"
 	getEventByIDurl    = "/api/user/event"
 	getEventOrdersURL  = "/api/event/orders"
+	getArchiveURL      = "/api/user/events/archive"
+	getEventReportURL  = "/api/event/report"
 
 	completeEventURL = "/api/event/complete"
+	cancelEventURL   = "/api/event/cancel"
 	updateEventURL   = "/api/event/update"
+
+	reviewShoppingURL = "/api/event/shopping/review"
 )
 
 type handler struct {
@@ -49,8 +54,13 @@ func NewHandler(logger *logging.Logger, service event.Service, userService user.
 func (h *handler) Register(router *httprouter.Router) {
 	router.HandlerFunc(http.MethodPost, createEventURL, apperror.Middleware(h.CreateEvent))
 	router.HandlerFunc(http.MethodPatch, completeEventURL, apperror.Middleware(h.CompleteEvent))
+	router.HandlerFunc(http.MethodPatch, cancelEventURL, apperror.Middleware(h.Verify(h.CancelEvent)))
+	router.HandlerFunc(http.MethodPatch, reviewShoppingURL, apperror.Middleware(h.Verify(h.ReviewShoppingList)))
 	router.HandlerFunc(http.MethodGet, getEventsByHostURL, apperror.Middleware(h.GetAllByHostID))
 	router.HandlerFunc(http.MethodGet, getEventByIDurl, apperror.Middleware(h.GetByID))
+	router.HandlerFunc(http.MethodGet, getArchiveURL, apperror.Middleware(h.Verify(h.GetArchive)))
+	router.HandlerFunc(http.MethodGet, getEventReportURL, apperror.Middleware(h.Verify(h.GetReport)))
+	router.HandlerFunc(http.MethodGet, exportEventURL, apperror.Middleware(h.Verify(h.ExportEvent)))
 	router.HandlerFunc(http.MethodGet, getEventOrdersURL, apperror.Middleware(h.GetEventOrders))
 	router.HandlerFunc(http.MethodPut, updateEventURL, apperror.Middleware(h.UpdateEvent))
 }
@@ -98,6 +108,55 @@ func (h *handler) CompleteEvent(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// Отменить ивент может только его организатор
+func (h *handler) CancelEvent(w http.ResponseWriter, r *http.Request) error {
+	var dto event.CancelEventDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	dto.UserID, err = h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	err = h.service.CancelEvent(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("event is cancelled"))
+
+	return nil
+}
+
+// ReviewShoppingList сбрасывает изменения списка покупок, которые хост просмотрел
+func (h *handler) ReviewShoppingList(w http.ResponseWriter, r *http.Request) error {
+	var dto event.ReviewShoppingDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	dto.UserID, err = h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	err = h.service.ReviewShoppingList(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
 func (h *handler) GetAllByHostID(w http.ResponseWriter, r *http.Request) error {
 	var dto event.FindAllEventsDTO
 
@@ -123,6 +182,35 @@ func (h *handler) GetAllByHostID(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// GetArchive возвращает завершенные и отмененные ивенты пользователя токена
+// со снимками меню и ингредиентов
+func (h *handler) GetArchive(w http.ResponseWriter, r *http.Request) error {
+	var (
+		dto event.FindArchiveDTO
+		err error
+	)
+
+	dto.UserID, err = h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.service.FindArchivedEvents(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBytes)
+
+	return nil
+}
+
 func (h *handler) GetByID(w http.ResponseWriter, r *http.Request) error {
 	var dto event.FindEventDTO
 
@@ -153,6 +241,40 @@ func (h *handler) GetByID(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// GetReport возвращает отчет, собранный при завершении ивента, его организатору
+func (h *handler) GetReport(w http.ResponseWriter, r *http.Request) error {
+	var (
+		dto event.FindReportDTO
+		err error
+	)
+
+	dto.EventID = r.URL.Query().Get("event_id")
+
+	if dto.EventID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	dto.UserID, err = h.userID(r)
+	if err != nil {
+		return err
+	}
+
+	rep, err := h.service.FindReport(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	respBytes, err := json.Marshal(rep)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBytes)
+
+	return nil
+}
+
 // TODO
 func (h *handler) GetEventOrders(w http.ResponseWriter, r *http.Request) error {
 	var dto bar.GetOrdersDTO
@@ -220,3 +342,20 @@ func (h *handler) Verify(protectedHandler apperror.AppHandler) apperror.AppHandl
 		return protectedHandler(w, r)
 	}
 }
+
+// userID возвращает пользователя по токену из cookie, уже проверенному Verify
+func (h *handler) userID(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("AccessToken")
+	if err != nil {
+		h.logger.Errorf("cookie error: %v", err)
+		return "", apperror.ErrUnauthorized
+	}
+
+	userID, err := h.userService.GetUserIDByToken(context.TODO(), cookie.Value)
+	if err != nil {
+		h.logger.Errorf("access token is wrong: %v", err)
+		return "", apperror.ErrUnauthorized
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,223 @@
+package tab_api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"restapi/internal/adapters"
+	"restapi/internal/apperror"
+	"restapi/internal/domain/tab"
+	"restapi/internal/domain/user"
+
+	"restapi/pkg/logging"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Подсказка, что структура реализует интерфейс
+var _ adapters.Handler = &handler{}
+
+const (
+	openTabURL      = "/api/event/tab/open"
+	getTabURL       = "/api/event/tab"
+	getEventTabsURL = "/api/event/tabs"
+	closeTabURL     = "/api/event/tab/close"
+	splitTabURL     = "/api/event/tab/split"
+	getEventBillURL = "/api/event/bill"
+)
+
+type handler struct {
+	service     tab.Service
+	userService user.Service
+	logger      *logging.Logger
+}
+
+func NewHandler(logger *logging.Logger, service tab.Service, userService user.Service) adapters.Handler {
+	return &handler{
+		service:     service,
+		userService: userService,
+		logger:      logger,
+	}
+}
+
+func (h *handler) Register(router *httprouter.Router) {
+	// Счет открывает и смотрит сам гость, у которого нет аккаунта: смотреть счет можно только по его секрету
+	router.HandlerFunc(http.MethodPost, openTabURL, apperror.Middleware(h.OpenTab))
+	router.HandlerFunc(http.MethodGet, getTabURL, apperror.Middleware(h.GetTab))
+
+	router.HandlerFunc(http.MethodGet, getEventTabsURL, apperror.Middleware(h.Verify(h.GetEventTabs)))
+	router.HandlerFunc(http.MethodPatch, closeTabURL, apperror.Middleware(h.Verify(h.CloseTab)))
+	router.HandlerFunc(http.MethodPatch, splitTabURL, apperror.Middleware(h.Verify(h.SplitTab)))
+	router.HandlerFunc(http.MethodGet, getEventBillURL, apperror.Middleware(h.Verify(h.GetEventBill)))
+}
+
+func (h *handler) OpenTab(w http.ResponseWriter, r *http.Request) error {
+	var dto tab.OpenTabDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	tb, err := h.service.OpenTab(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong tab data", err.Error(), "US-000009")
+	}
+
+	respBytes, err := json.Marshal(tb)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBytes)
+
+	return nil
+}
+
+func (h *handler) GetTab(w http.ResponseWriter, r *http.Request) error {
+	var dto tab.FindTabDTO
+
+	dto.ID = r.URL.Query().Get("id")
+	dto.Secret = r.URL.Query().Get("secret")
+
+	if dto.ID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param id is empty", "US-000015")
+	}
+
+	if dto.Secret == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param secret is empty", "US-000015")
+	}
+
+	tb, err := h.service.FindTab(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	respBytes, err := json.Marshal(tb)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBytes)
+
+	return nil
+}
+
+func (h *handler) GetEventTabs(w http.ResponseWriter, r *http.Request) error {
+	var dto tab.FindEventTabsDTO
+
+	dto.EventID = r.URL.Query().Get("event_id")
+
+	if dto.EventID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	resp, err := h.service.FindEventTabs(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBytes)
+
+	return nil
+}
+
+func (h *handler) CloseTab(w http.ResponseWriter, r *http.Request) error {
+	var dto tab.CloseTabDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	err = h.service.CloseTab(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("tab is closed"))
+
+	return nil
+}
+
+func (h *handler) SplitTab(w http.ResponseWriter, r *http.Request) error {
+	var dto tab.SplitTabDTO
+
+	err := json.NewDecoder(r.Body).Decode(&dto)
+	if err != nil {
+		return err
+	}
+
+	tb, err := h.service.SplitTab(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong tab split data", err.Error(), "US-000009")
+	}
+
+	respBytes, err := json.Marshal(tb)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBytes)
+
+	return nil
+}
+
+func (h *handler) GetEventBill(w http.ResponseWriter, r *http.Request) error {
+	var dto tab.FindEventTabsDTO
+
+	dto.EventID = r.URL.Query().Get("event_id")
+
+	if dto.EventID == "" {
+		return apperror.NewAppError(nil, "query param is empty", "param event_id is empty", "US-000015")
+	}
+
+	bill, err := h.service.GetEventBill(context.TODO(), dto)
+	if err != nil {
+		return apperror.NewAppError(err, "wrong id", err.Error(), "US-000009")
+	}
+
+	respBytes, err := json.Marshal(bill)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(respBytes)
+
+	return nil
+}
+
+func (h *handler) Verify(protectedHandler apperror.AppHandler) apperror.AppHandler {
+
+	return func(w http.ResponseWriter, r *http.Request) error {
+		cookie, err := r.Cookie("AccessToken")
+		if err != nil {
+			h.logger.Errorf("cookie error: %v", err)
+			return apperror.ErrUnauthorized
+		}
+
+		if cookie.Value == "" {
+			h.logger.Errorf("access token is empty")
+			return apperror.ErrUnauthorized
+		}
+
+		err = h.userService.Verify(context.TODO(), cookie.Value)
+		if err != nil {
+			h.logger.Errorf("access token is wrong: %v", err)
+			return apperror.ErrUnauthorized
+		}
+
+		return protectedHandler(w, r)
+	}
+}
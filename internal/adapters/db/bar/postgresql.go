@@ -8,13 +8,18 @@ import (
 	"restapi/pkg/client/postgresql"
 	"restapi/pkg/logging"
 	repeatable "restapi/pkg/utils"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
 const (
-	statusOpened = "Opened"
-	statusClosed = "Closed"
+	// код ошибки PostgreSQL при нарушении уникальности
+	uniqueViolation = "23505"
+
+	// уникальный индекс старшего бармена бара, см. migrations
+	headBartenderIndex = "bar_staff_head_bartender_idx"
 )
 
 type repository struct {
@@ -22,12 +27,12 @@ type repository struct {
 	logger *logging.Logger
 }
 
-func (r *repository) CreateBar(ctx context.Context, dto bar.CreateBarDTO) (uint32, error) {
+func (r *repository) CreateBar(ctx context.Context, dto bar.CreateBarDTO, sessionToken, sessionURL string) (uint32, error) {
 	q := `
 	INSERT INTO bars
-    	(event_id, name, description, status)
+    	(event_id, name, description, status, session_token, session_url)
 	VALUES
-    	($1, $2, $3, $4)
+    	($1, $2, $3, $4, $5, $6)
 	RETURNING
     	id
 	`
@@ -35,7 +40,7 @@ func (r *repository) CreateBar(ctx context.Context, dto bar.CreateBarDTO) (uint3
 
 	var barID uint32
 
-	row := r.client.QueryRow(ctx, q, dto.EventID, dto.Name, dto.Description, statusOpened)
+	row := r.client.QueryRow(ctx, q, dto.EventID, dto.Name, dto.Description, bar.StatusOpened, sessionToken, sessionURL)
 	err := row.Scan(&barID)
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -52,18 +57,84 @@ func (r *repository) CreateBar(ctx context.Context, dto bar.CreateBarDTO) (uint3
 	return barID, nil
 }
 
-// Не удаляет бар из таблицы, а устанавливает статус "Closed"
-func (r *repository) CloseBar(ctx context.Context, dto bar.CloseBarDTO) error {
+func (r *repository) FindBySession(ctx context.Context, sessionToken string) (bar.Bar, error) {
+	q := `
+	SELECT
+		id, event_id, name, description, status, COALESCE(session_url, ''), session_token
+	FROM
+		bars
+	WHERE
+		session_token = $1
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var br bar.Bar
+
+	err := r.client.QueryRow(ctx, q, sessionToken).Scan(&br.ID, &br.EventID, &br.Name, &br.Description, &br.Status,
+		&br.SessionURL, &br.SessionToken)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return bar.Bar{}, newErr
+		}
+
+		return bar.Bar{}, err
+	}
+
+	return br, nil
+}
+
+// Не удаляет бар из таблицы, а устанавливает статус "Closed".
+// Возвращает закрытый бар, чтобы завершить его сессию
+func (r *repository) CloseBar(ctx context.Context, dto bar.CloseBarDTO) (bar.Bar, error) {
 	q := `
 	UPDATE bars
 	SET
     	status = $2
 	WHERE
     	id = $1
+	RETURNING
+		id, event_id, name, description, status, COALESCE(session_url, ''), COALESCE(session_token, '')
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var br bar.Bar
+
+	err := r.client.QueryRow(ctx, q, dto.ID, bar.StatusClosed).Scan(&br.ID, &br.EventID, &br.Name, &br.Description,
+		&br.Status, &br.SessionURL, &br.SessionToken)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return bar.Bar{}, fmt.Errorf("database updating error: bar not found")
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return bar.Bar{}, newErr
+		}
+
+		return bar.Bar{}, err
+	}
+
+	return br, nil
+}
+
+func (r *repository) UpdateInfo(ctx context.Context, dto bar.UpdateBarDTO) error {
+	q := `
+	UPDATE bars
+	SET
+		name = $2, description = $3
+	WHERE
+		id = $1
 	`
 	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
 
-	ct, err := r.client.Exec(ctx, q, dto.ID, statusClosed)
+	ct, err := r.client.Exec(ctx, q, dto.ID, dto.Name, dto.Description)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -84,18 +155,50 @@ func (r *repository) CloseBar(ctx context.Context, dto bar.CloseBarDTO) error {
 	return nil
 }
 
-func (r *repository) GetOrders(ctx context.Context, dto bar.GetOrdersDTO) ([]string, error) {
+func (r *repository) FindBar(ctx context.Context, dto bar.FindBarDTO) (bar.Bar, error) {
 	q := `
 	SELECT
-    	orders
-	FROM 
-    	bars
-	WHERE 
-    	event_id = $1
+		id, event_id, name, description, status, COALESCE(session_url, ''), COALESCE(session_token, '')
+	FROM
+		bars
+	WHERE
+		id = $1
 	`
 	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
 
-	rows, err := r.client.Query(ctx, q, dto.EventID)
+	var br bar.Bar
+
+	err := r.client.QueryRow(ctx, q, dto.ID).Scan(&br.ID, &br.EventID, &br.Name, &br.Description, &br.Status,
+		&br.SessionURL, &br.SessionToken)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return bar.Bar{}, newErr
+		}
+
+		return bar.Bar{}, err
+	}
+
+	return br, nil
+}
+
+// Пустой dto.Status - бары ивента в любом статусе
+func (r *repository) FindEventBars(ctx context.Context, dto bar.FindEventBarsDTO) ([]bar.Bar, error) {
+	q := `
+	SELECT
+		id, event_id, name, description, status, COALESCE(session_url, ''), COALESCE(session_token, '')
+	FROM
+		bars
+	WHERE
+		event_id = $1 AND ($2 = '' OR status = $2)
+	ORDER BY id ASC
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.EventID, dto.Status)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -107,13 +210,14 @@ func (r *repository) GetOrders(ctx context.Context, dto bar.GetOrdersDTO) ([]str
 
 		return nil, err
 	}
+	defer rows.Close()
 
-	ordersID := make([]string, 0)
+	bars := make([]bar.Bar, 0)
 
 	for rows.Next() {
-		var ordrs []string
+		var br bar.Bar
 
-		err = rows.Scan(&ordrs)
+		err = rows.Scan(&br.ID, &br.EventID, &br.Name, &br.Description, &br.Status, &br.SessionURL, &br.SessionToken)
 		if err != nil {
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) {
@@ -126,56 +230,65 @@ func (r *repository) GetOrders(ctx context.Context, dto bar.GetOrdersDTO) ([]str
 			return nil, err
 		}
 
-		ordersID = append(ordersID, ordrs...)
+		bars = append(bars, br)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return ordersID, nil
+	return bars, nil
 }
 
-func (r *repository) GetBarOrders(ctx context.Context, dto bar.GetBarOrdersDTO) ([]string, error) {
+// Назначение на бар повторно меняет роль сотрудника
+// Старший бармен в баре один: это обеспечивает уникальный индекс headBartenderIndex
+func (r *repository) AssignStaff(ctx context.Context, dto bar.AssignStaffDTO) (bar.Staff, error) {
 	q := `
-	SELECT
-    	orders
-	FROM 
-    	bars
-	WHERE 
-    	id = $1 AND event_id = $2
+	INSERT INTO bar_staff
+		(bar_id, user_id, role, assigned_at)
+	VALUES
+		($1, $2, $3, now())
+	ON CONFLICT (bar_id, user_id) DO UPDATE
+	SET
+		role = EXCLUDED.role
+	RETURNING
+		bar_id, user_id, role, assigned_at
 	`
 	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
 
-	ordersID := make([]string, 0)
+	var member bar.Staff
 
-	err := r.client.QueryRow(ctx, q, dto.ID, dto.EventID).Scan(&ordersID)
+	err := r.client.QueryRow(ctx, q, dto.BarID, dto.UserID, dto.Role).Scan(&member.BarID, &member.UserID,
+		&member.Role, &member.AssignedAt)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			pgErr = err.(*pgconn.PgError)
+			if pgErr.Code == uniqueViolation && pgErr.ConstraintName == headBartenderIndex {
+				return bar.Staff{}, fmt.Errorf("bar %d already has head bartender", dto.BarID)
+			}
+
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
 			r.logger.Error(newErr)
-			return nil, newErr
+			return bar.Staff{}, newErr
 		}
 
-		return nil, err
+		return bar.Staff{}, err
 	}
 
-	return ordersID, nil
+	return member, nil
 }
 
-func (r *repository) UpdateInfo(ctx context.Context, dto bar.UpdateBarDTO) error {
+func (r *repository) UnassignStaff(ctx context.Context, dto bar.UnassignStaffDTO) error {
 	q := `
-	UPDATE bars
-	SET
-		name = $2, description = $2, orders = $3, session_url = $4
+	DELETE FROM
+		bar_staff
 	WHERE
-		id = $1
+		bar_id = $1 AND user_id = $2
 	`
 	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
 
-	ct, err := r.client.Exec(ctx, q, dto.ID, dto.Name, dto.Description, dto.Orders, dto.SessionURL)
+	ct, err := r.client.Exec(ctx, q, dto.BarID, dto.UserID)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -188,14 +301,245 @@ func (r *repository) UpdateInfo(ctx context.Context, dto bar.UpdateBarDTO) error
 		return err
 	}
 
-	if ct.String() != "UPDATE 1" {
-		err := fmt.Errorf("database updating error: bar not found")
+	if ct.String() != "DELETE 1" {
+		err := fmt.Errorf("database deleting error: staff member not found")
 		return err
 	}
 
 	return nil
 }
 
+func (r *repository) FindBarStaff(ctx context.Context, dto bar.FindBarStaffDTO) ([]bar.Staff, error) {
+	q := `
+	SELECT
+		bar_id, user_id, role, assigned_at
+	FROM
+		bar_staff
+	WHERE
+		bar_id = $1
+	ORDER BY assigned_at ASC
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.BarID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return nil, newErr
+		}
+
+		return nil, err
+	}
+
+	return r.scanStaff(rows)
+}
+
+func (r *repository) FindStaffMember(ctx context.Context, dto bar.FindStaffMemberDTO) (bar.Staff, error) {
+	q := `
+	SELECT
+		bar_id, user_id, role, assigned_at
+	FROM
+		bar_staff
+	WHERE
+		bar_id = $1 AND user_id = $2
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var member bar.Staff
+
+	err := r.client.QueryRow(ctx, q, dto.BarID, dto.UserID).Scan(&member.BarID, &member.UserID, &member.Role,
+		&member.AssignedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return bar.Staff{}, newErr
+		}
+
+		return bar.Staff{}, err
+	}
+
+	return member, nil
+}
+
+func (r *repository) FindUserStaff(ctx context.Context, dto bar.FindUserStaffDTO) ([]bar.Staff, error) {
+	q := `
+	SELECT
+		bar_id, user_id, role, assigned_at
+	FROM
+		bar_staff
+	WHERE
+		user_id = $1
+	ORDER BY assigned_at ASC
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.UserID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return nil, newErr
+		}
+
+		return nil, err
+	}
+
+	return r.scanStaff(rows)
+}
+
+func (r *repository) scanStaff(rows pgx.Rows) ([]bar.Staff, error) {
+	defer rows.Close()
+
+	staff := make([]bar.Staff, 0)
+
+	for rows.Next() {
+		var member bar.Staff
+
+		err := rows.Scan(&member.BarID, &member.UserID, &member.Role, &member.AssignedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				r.logger.Error(newErr)
+				return nil, newErr
+			}
+
+			return nil, err
+		}
+
+		staff = append(staff, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return staff, nil
+}
+
+// В таблице хранятся только недоступные напитки:
+// доступный напиток удаляется из нее, недоступный - добавляется
+func (r *repository) SetDrinkAvailability(ctx context.Context, dto bar.SetDrinkAvailabilityDTO) (bar.DrinkAvailability, error) {
+	q := `
+	INSERT INTO bar_unavailable_drinks
+		(bar_id, drink_id, changed_by, changed_at)
+	VALUES
+		($1, $2, $3, now())
+	ON CONFLICT (bar_id, drink_id) DO UPDATE
+	SET
+		changed_by = EXCLUDED.changed_by, changed_at = EXCLUDED.changed_at
+	RETURNING
+		changed_at
+	`
+	if dto.Available {
+		q = `
+		DELETE FROM
+			bar_unavailable_drinks
+		WHERE
+			bar_id = $1 AND drink_id = $2
+		RETURNING
+			now()
+		`
+	}
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	availability := bar.DrinkAvailability{
+		BarID:     dto.BarID,
+		DrinkID:   dto.DrinkID,
+		Available: dto.Available,
+		ChangedBy: dto.UserID,
+	}
+
+	args := []any{dto.BarID, dto.DrinkID}
+	if !dto.Available {
+		args = append(args, dto.UserID)
+	}
+
+	err := r.client.QueryRow(ctx, q, args...).Scan(&availability.ChangedAt)
+	if err != nil {
+		// Напиток уже был доступен
+		if errors.Is(err, pgx.ErrNoRows) {
+			availability.ChangedAt = time.Now()
+			return availability, nil
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return bar.DrinkAvailability{}, newErr
+		}
+
+		return bar.DrinkAvailability{}, err
+	}
+
+	return availability, nil
+}
+
+func (r *repository) FindUnavailableDrinks(ctx context.Context, dto bar.FindUnavailableDrinksDTO) ([]bar.DrinkAvailability, error) {
+	q := `
+	SELECT
+		bar_id, drink_id, changed_by, changed_at
+	FROM
+		bar_unavailable_drinks
+	WHERE
+		bar_id = $1
+	ORDER BY changed_at ASC
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.BarID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return nil, newErr
+		}
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	drinks := make([]bar.DrinkAvailability, 0)
+
+	for rows.Next() {
+		var availability bar.DrinkAvailability
+
+		err = rows.Scan(&availability.BarID, &availability.DrinkID, &availability.ChangedBy, &availability.ChangedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				r.logger.Error(newErr)
+				return nil, newErr
+			}
+
+			return nil, err
+		}
+
+		drinks = append(drinks, availability)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return drinks, nil
+}
+
 func NewRepository(client postgresql.Client, logger *logging.Logger) bar.Repository {
 	return &repository{
 		client: client,
@@ -0,0 +1,220 @@
+package broker_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi/pkg/logging"
+	repeatable "restapi/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Postgres ограничивает payload NOTIFY 8000 байтами.
+	// Сообщения больше сохраняются в hub_messages, а в NOTIFY передается их id
+	maxNotifyPayload = 7900
+	refPrefix        = "#"
+
+	// Через сколько переподключаться к LISTEN после потери соединения
+	reconnectDelay = 5 * time.Second
+
+	// Как часто удалять из hub_messages старые сообщения
+	cleanupPeriod = time.Minute
+
+	// Сколько хранится сообщение в hub_messages. Намного больше reconnectDelay,
+	// чтобы экземпляр, который отстал или переподключался, еще нашел сообщение по id
+	messageTTL = 10 * time.Minute
+)
+
+// Broker рассылает сообщения всем экземплярам приложения через NOTIFY
+// и получает их через LISTEN на отдельном соединении из пула
+type Broker struct {
+	pool    *pgxpool.Pool
+	channel string
+	logger  *logging.Logger
+}
+
+func NewBroker(pool *pgxpool.Pool, channel string, logger *logging.Logger) *Broker {
+	return &Broker{
+		pool:    pool,
+		channel: channel,
+		logger:  logger,
+	}
+}
+
+func (b *Broker) Publish(ctx context.Context, data []byte) error {
+	q := `
+	SELECT pg_notify($1, $2)
+	`
+
+	if len(data) > maxNotifyPayload {
+		q = `
+		WITH msg AS (
+			INSERT INTO hub_messages
+				(payload, created_at)
+			VALUES
+				($2, now())
+			RETURNING
+				id
+		)
+		SELECT pg_notify($1, '` + refPrefix + `' || id) FROM msg
+		`
+	}
+	b.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	_, err := b.pool.Exec(ctx, q, b.channel, string(data))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			b.logger.Error(newErr)
+			return newErr
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Subscribe слушает канал, пока не отменен ctx, и переподключается при потере соединения.
+// Сообщения, опубликованные во время переподключения, теряются.
+// Пока экземпляр подписан, он периодически удаляет старые сообщения из hub_messages
+func (b *Broker) Subscribe(ctx context.Context, handler func(data []byte)) error {
+	go b.cleanup(ctx)
+
+	for {
+		err := b.listen(ctx, handler)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		b.logger.Errorf("listening channel %s error: %v", b.channel, err)
+
+		select {
+		case <-time.After(reconnectDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (b *Broker) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(cleanupPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.deleteExpired(ctx); err != nil {
+				b.logger.Errorf("deleting expired hub messages error: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *Broker) deleteExpired(ctx context.Context) error {
+	q := `
+	DELETE FROM
+		hub_messages
+	WHERE
+		created_at < now() - make_interval(secs => $1)
+	`
+	b.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	_, err := b.pool.Exec(ctx, q, messageTTL.Seconds())
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			b.logger.Error(newErr)
+			return newErr
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (b *Broker) listen(ctx context.Context, handler func(data []byte)) error {
+	conn, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Соединение возвращается в пул без подписки
+		unlistenCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		conn.Exec(unlistenCtx, "UNLISTEN *")
+		conn.Release()
+	}()
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize())
+	if err != nil {
+		return err
+	}
+
+	b.logger.Infof("listening channel %s", b.channel)
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		data := []byte(notification.Payload)
+
+		if strings.HasPrefix(notification.Payload, refPrefix) {
+			ref := strings.TrimPrefix(notification.Payload, refPrefix)
+
+			data, err = b.findMessage(ctx, ref)
+			if errors.Is(err, pgx.ErrNoRows) {
+				b.logger.Errorf("hub message %s is already deleted, the message is lost", ref)
+				continue
+			}
+			if err != nil {
+				b.logger.Errorf("finding hub message %s error: %v", ref, err)
+				continue
+			}
+		}
+
+		handler(data)
+	}
+}
+
+func (b *Broker) findMessage(ctx context.Context, ref string) ([]byte, error) {
+	q := `
+	SELECT
+		payload
+	FROM
+		hub_messages
+	WHERE
+		id = $1
+	`
+	b.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	id, err := strconv.ParseUint(ref, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload string
+
+	err = b.pool.QueryRow(ctx, q, id).Scan(&payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(payload), nil
+}
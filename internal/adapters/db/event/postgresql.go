@@ -5,10 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"restapi/internal/domain/event"
+	"restapi/internal/domain/menu"
+	"restapi/internal/domain/report"
 	"restapi/pkg/client/postgresql"
 	"restapi/pkg/logging"
 	repeatable "restapi/pkg/utils"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
@@ -16,19 +20,31 @@ const (
 	statusCreated   = "Created"
 	statusActive    = "Active"
 	statusCompleted = "Completed"
+	statusCancelled = "Cancelled"
 )
 
+// Текущий и просмотренный хостом списки покупок.
+// Пока хост не просматривал изменения, просмотренным считается текущий список
+const shoppingColumns = `
+	COALESCE(shopping_list, '[]'), COALESCE(reviewed_shopping_list, shopping_list, '[]')`
+
+// Колонки ивента вместе с архивом, порядок совпадает со scanEvent
+const eventColumns = `
+	id, user_id, name, description, participants_number, date_time, end_time, COALESCE(timezone, ''),
+	status, menu_id, COALESCE(drinks_per_guest, '{}'), COALESCE(shopping_list, '[]'), shopping_diff, closed_at, snapshot, report`
+
 type repository struct {
 	client postgresql.Client
 	logger *logging.Logger
 }
 
-func (r *repository) CreateEvent(ctx context.Context, dto event.CreateEventDTO, shopList []string) (string, error) {
+func (r *repository) CreateEvent(ctx context.Context, dto event.CreateEventDTO, shopList []event.ShoppingItem) (string, error) {
 	q := `
 	INSERT INTO events
-    	(user_id, name, description, participants_number, date_time, status, menu_id, shopping_list)
+    	(user_id, name, description, participants_number, date_time, end_time, timezone, status, menu_id,
+		drinks_per_guest, shopping_list)
 	VALUES
-    	($1, $2, $3, $4, $5, $6, $7, $8)
+    	($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	RETURNING
     	id
 	`
@@ -37,7 +53,8 @@ func (r *repository) CreateEvent(ctx context.Context, dto event.CreateEventDTO,
 	var eventID string
 
 	row := r.client.QueryRow(ctx, q, dto.UserID, dto.Name, dto.Description, dto.ParticipantsNumber,
-		dto.DateTime, statusCreated, dto.MenuID, shopList)
+		dto.DateTime, dto.EndTime, dto.Timezone, statusCreated, dto.MenuID,
+		dto.DrinksPerGuest, shopList)
 	err := row.Scan(&eventID)
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -54,73 +71,203 @@ func (r *repository) CreateEvent(ctx context.Context, dto event.CreateEventDTO,
 	return eventID, nil
 }
 
-func (r *repository) SetActive(ctx context.Context, event_id string) error {
+// Активирует только созданный ивент, время начала которого не позже at.
+// Возвращает false, если ивент уже активен или завершен либо его начало перенесли на более позднее время
+func (r *repository) SetActive(ctx context.Context, event_id string, at time.Time) (bool, error) {
 	q := `
 	UPDATE events
 	SET 
 		status = $2
 	WHERE 
-		id = $1
+		id = $1 AND status = $3 AND date_time <= $4
 	`
 	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
 
-	ct, err := r.client.Exec(ctx, q, event_id, statusActive)
+	ct, err := r.client.Exec(ctx, q, event_id, statusActive, statusCreated, at)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			pgErr = err.(*pgconn.PgError)
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
 			r.logger.Error(newErr)
-			return newErr
+			return false, newErr
 		}
 
-		return err
+		return false, err
 	}
 
-	if ct.String() != "UPDATE 1" {
-		err := fmt.Errorf("database updating error: event not found")
-		return err
+	return ct.RowsAffected() == 1, nil
+}
+
+// Ивенты, которые еще ждут активации или автоматического завершения
+func (r *repository) FindScheduledEvents(ctx context.Context) ([]event.Event, error) {
+	q := `
+	SELECT 
+    	id, date_time, end_time, status
+	FROM 
+    	events
+	WHERE
+		status = $1 OR (status = $2 AND end_time IS NOT NULL)
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, statusCreated, statusActive)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	events := make([]event.Event, 0)
+
+	for rows.Next() {
+		var evnt event.Event
+
+		err = rows.Scan(&evnt.ID, &evnt.DateTime, &evnt.EndTime, &evnt.Status)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				r.logger.Error(newErr)
+				return nil, newErr
+			}
+
+			return nil, err
+		}
+
+		events = append(events, evnt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Завершает созданный или активный ивент и сохраняет снимок его меню и ингредиентов и отчет.
+// Если задан endedBy, ивент завершается, только если время его окончания не позже endedBy.
+// Возвращает false, если ивент не найден, уже завершен либо отменен или еще не закончился
+func (r *repository) CompleteEvent(ctx context.Context, eventID string, mn menu.Menu, rep report.Report, endedBy *time.Time) (bool, error) {
+	q := `
+	UPDATE events e
+	SET 
+		status = $2, closed_at = now(),
+		snapshot = jsonb_build_object(
+			'menu', $3::jsonb,
+			'ingredients', COALESCE((
+				SELECT jsonb_agg(jsonb_build_object(
+					'type', i.type, 'name', i.name, 'unit', i.unit, 'volume', i.volume, 'cost', i.cost) ORDER BY i.type, i.name)
+				FROM ingredients i
+				WHERE i.event_id = e.id
+			), '[]')),
+		report = $6
+	WHERE 
+		e.id = $1 AND e.status IN ($4, $5)
+	`
+	args := []any{eventID, statusCompleted, mn, statusCreated, statusActive, rep}
+
+	if endedBy != nil {
+		q += `AND e.end_time <= $7
+	`
+		args = append(args, *endedBy)
+	}
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	ct, err := r.client.Exec(ctx, q, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return false, newErr
+		}
+
+		return false, err
+	}
+
+	return ct.String() == "UPDATE 1", nil
 }
 
-// Не удаляет ивент из таблицы, а устанавливает статус "Completed"
-func (r *repository) DeleteEvent(ctx context.Context, dto event.CompleteEventDTO) error {
+// Отменяет ивент пользователя, который еще не завершен. Возвращает false, если ивент
+// не найден, принадлежит другому пользователю или уже завершен либо отменен
+func (r *repository) CancelEvent(ctx context.Context, dto event.CancelEventDTO) (bool, error) {
 	q := `
 	UPDATE events
 	SET 
-		status = $2
+		status = $2, closed_at = now()
 	WHERE 
-		id = $1
+		id = $1 AND user_id = $5 AND status IN ($3, $4)
 	`
 	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
 
-	ct, err := r.client.Exec(ctx, q, dto.ID, statusCompleted)
+	ct, err := r.client.Exec(ctx, q, dto.ID, statusCancelled, statusCreated, statusActive, dto.UserID)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			pgErr = err.(*pgconn.PgError)
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
 			r.logger.Error(newErr)
-			return newErr
+			return false, newErr
 		}
 
-		return err
+		return false, err
 	}
 
-	if ct.String() != "UPDATE 1" {
-		err := fmt.Errorf("database updating error: event not found")
-		return err
+	return ct.String() == "UPDATE 1", nil
+}
+
+// Ингредиенты, закупленные на ивент, для подсчета стоимости расхода в отчете
+func (r *repository) FindPurchases(ctx context.Context, eventID string) ([]report.Purchase, error) {
+	q := `
+	SELECT 
+    	type, name, COALESCE(unit, ''), volume, cost
+	FROM 
+    	ingredients
+	WHERE
+		event_id = $1
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, eventID)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	purchases := make([]report.Purchase, 0)
+
+	for rows.Next() {
+		var p report.Purchase
+
+		err = rows.Scan(&p.Type, &p.Name, &p.Unit, &p.Volume, &p.Cost)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				r.logger.Error(newErr)
+				return nil, newErr
+			}
+
+			return nil, err
+		}
+
+		purchases = append(purchases, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return purchases, nil
 }
 
 func (r *repository) FindAllUserEvents(ctx context.Context, dto event.FindAllEventsDTO) (event.RespAllEvents, error) {
 	q := `
 	SELECT 
-    	id, user_id, name, description, participants_number, date_time, status
+    	id, user_id, name, description, participants_number, date_time, end_time, COALESCE(timezone, ''), status
 	FROM 
     	events
 	WHERE
@@ -139,7 +286,7 @@ func (r *repository) FindAllUserEvents(ctx context.Context, dto event.FindAllEve
 		var evnt event.Event
 
 		err = rows.Scan(&evnt.ID, &evnt.UserID, &evnt.Name, &evnt.Description,
-			&evnt.ParticipantsNumber, &evnt.DateTime, &evnt.Status)
+			&evnt.ParticipantsNumber, &evnt.DateTime, &evnt.EndTime, &evnt.Timezone, &evnt.Status)
 		if err != nil {
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) {
@@ -163,21 +310,139 @@ func (r *repository) FindAllUserEvents(ctx context.Context, dto event.FindAllEve
 }
 
 func (r *repository) FindUserEvent(ctx context.Context, dto event.FindEventDTO) (event.Event, error) {
-	q := `
-	SELECT 
-    	id, user_id, name, description, participants_number, date_time, status, menu_id, shopping_list
+	q := fmt.Sprintf(`
+	SELECT %s
 	FROM 
     	events
 	WHERE
     	id = $1 AND user_id = $2
-	`
+	`, eventColumns)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	evnt, err := scanEvent(r.client.QueryRow(ctx, q, dto.ID, dto.UserID))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return event.Event{}, newErr
+		}
+
+		return event.Event{}, err
+	}
+
+	return evnt, nil
+}
+
+func (r *repository) FindEvent(ctx context.Context, eventID string) (event.Event, error) {
+	q := fmt.Sprintf(`
+	SELECT %s
+	FROM 
+    	events
+	WHERE
+    	id = $1
+	`, eventColumns)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	evnt, err := scanEvent(r.client.QueryRow(ctx, q, eventID))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return event.Event{}, newErr
+		}
+
+		return event.Event{}, err
+	}
+
+	return evnt, nil
+}
+
+// Завершенные и отмененные ивенты пользователя вместе со снимками, сначала последние
+func (r *repository) FindArchivedEvents(ctx context.Context, dto event.FindArchiveDTO) (event.RespAllEvents, error) {
+	q := fmt.Sprintf(`
+	SELECT %s
+	FROM 
+    	events
+	WHERE
+		user_id = $1 AND status IN ($2, $3)
+	ORDER BY date_time DESC
+	`, eventColumns)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.UserID, statusCompleted, statusCancelled)
+	if err != nil {
+		return event.RespAllEvents{}, err
+	}
+	defer rows.Close()
+
+	resp := event.RespAllEvents{Events: make([]event.Event, 0)}
+
+	for rows.Next() {
+		evnt, err := scanEvent(rows)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				r.logger.Error(newErr)
+				return event.RespAllEvents{}, newErr
+			}
+
+			return event.RespAllEvents{}, err
+		}
+
+		resp.Events = append(resp.Events, evnt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return event.RespAllEvents{}, err
+	}
+
+	return resp, nil
+}
+
+func scanEvent(row pgx.Row) (event.Event, error) {
+	var evnt event.Event
+
+	err := row.Scan(&evnt.ID, &evnt.UserID, &evnt.Name, &evnt.Description, &evnt.ParticipantsNumber,
+		&evnt.DateTime, &evnt.EndTime, &evnt.Timezone, &evnt.Status, &evnt.MenuID, &evnt.DrinksPerGuest, &evnt.ShoppingList, &evnt.ShoppingDiff,
+		&evnt.ClosedAt, &evnt.Snapshot, &evnt.Report)
+
+	return evnt, err
+}
+
+// Изменить можно только незавершенный ивент, архив остается прежним.
+// Возвращает статус, время и параметры списка покупок ивента после изменения,
+// чтобы перепланировать его активацию и завершение и пересчитать покупки
+func (r *repository) UpdateEvent(ctx context.Context, dto event.UpdateEventDTO) (event.Event, error) {
+	q := fmt.Sprintf(`
+	UPDATE events
+	SET 
+		name = $2, description = $3, participants_number = $4, date_time = $5, end_time = $6,
+		timezone = $7, drinks_per_guest = $8
+	WHERE 
+		id = $1 AND status IN ($9, $10)
+	RETURNING
+		id, participants_number, date_time, end_time, timezone, status, menu_id, COALESCE(drinks_per_guest, '{}'), %s
+	`, shoppingColumns)
 	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
 
 	var evnt event.Event
 
-	err := r.client.QueryRow(ctx, q, dto.ID, dto.UserID).Scan(&evnt.ID, &evnt.UserID, &evnt.Name, &evnt.Description,
-		&evnt.ParticipantsNumber, &evnt.DateTime, &evnt.Status, &evnt.MenuID, &evnt.ShoppingList)
+	err := r.client.QueryRow(ctx, q, dto.ID, dto.Name, dto.Description, dto.ParticipantsNumber,
+		dto.DateTime, dto.EndTime, dto.Timezone, dto.DrinksPerGuest, statusCreated, statusActive).Scan(&evnt.ID,
+		&evnt.ParticipantsNumber, &evnt.DateTime,
+		&evnt.EndTime, &evnt.Timezone, &evnt.Status, &evnt.MenuID, &evnt.DrinksPerGuest, &evnt.ShoppingList,
+		&evnt.ReviewedShoppingList)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return event.Event{}, fmt.Errorf("database updating error: event not found or already completed")
+		}
+
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			pgErr = err.(*pgconn.PgError)
@@ -192,17 +457,57 @@ func (r *repository) FindUserEvent(ctx context.Context, dto event.FindEventDTO)
 	return evnt, nil
 }
 
-func (r *repository) UpdateEvent(ctx context.Context, dto event.UpdateEventDTO) error {
+// Сохраняет пересчитанный список покупок вместе с изменениями с последнего просмотра.
+// diff = nil, если список совпадает с просмотренным. diff посчитан от reviewed, поэтому
+// список сохраняется, только если просмотренный список в строке все еще равен reviewed
+// (так, как его читает shoppingColumns) и ивент не закрыт. Если список еще не просматривался,
+// просмотренным становится прежний список в той же строке.
+// Возвращает false, если ивент не найден, закрыт или хост уже просмотрел другой список
+func (r *repository) UpdateShoppingList(ctx context.Context, eventID string, list []event.ShoppingItem,
+	diff *event.ShoppingDiff, reviewed []event.ShoppingItem) (bool, error) {
 	q := `
 	UPDATE events
 	SET 
-		name = $2, description = $3, participants_number = $4, date_time = $5
+		shopping_list = $2, reviewed_shopping_list = COALESCE(reviewed_shopping_list, shopping_list), shopping_diff = $3
 	WHERE 
-		id = $1
+		id = $1 AND status IN ($5, $6)
+		AND COALESCE(reviewed_shopping_list, shopping_list, '[]') IS NOT DISTINCT FROM $4
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	if reviewed == nil {
+		reviewed = []event.ShoppingItem{}
+	}
+
+	ct, err := r.client.Exec(ctx, q, eventID, list, diff, reviewed, statusCreated, statusActive)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return false, newErr
+		}
+
+		return false, err
+	}
+
+	return ct.RowsAffected() == 1, nil
+}
+
+// Хост просмотрел изменения: текущий список становится просмотренным, изменения сбрасываются.
+// Изменения ивента может просмотреть только его организатор
+func (r *repository) ReviewShoppingList(ctx context.Context, dto event.ReviewShoppingDTO) error {
+	q := `
+	UPDATE events
+	SET 
+		reviewed_shopping_list = shopping_list, shopping_diff = NULL
+	WHERE 
+		id = $1 AND user_id = $2
 	`
 	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
 
-	ct, err := r.client.Exec(ctx, q, dto.ID, dto.Name, dto.Description, dto.ParticipantsNumber, dto.DateTime)
+	ct, err := r.client.Exec(ctx, q, dto.ID, dto.UserID)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -223,6 +528,53 @@ func (r *repository) UpdateEvent(ctx context.Context, dto event.UpdateEventDTO)
 	return nil
 }
 
+// Созданные и активные ивенты с этим меню, у которых нужно пересчитать список покупок
+func (r *repository) FindMenuEvents(ctx context.Context, menuID string) ([]event.Event, error) {
+	q := fmt.Sprintf(`
+	SELECT 
+    	id, participants_number, status, menu_id, COALESCE(drinks_per_guest, '{}'), %s
+	FROM 
+    	events
+	WHERE
+		menu_id = $1 AND status IN ($2, $3)
+	`, shoppingColumns)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, menuID, statusCreated, statusActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]event.Event, 0)
+
+	for rows.Next() {
+		var evnt event.Event
+
+		err = rows.Scan(&evnt.ID, &evnt.ParticipantsNumber, &evnt.Status, &evnt.MenuID, &evnt.DrinksPerGuest,
+			&evnt.ShoppingList, &evnt.ReviewedShoppingList)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				r.logger.Error(newErr)
+				return nil, newErr
+			}
+
+			return nil, err
+		}
+
+		events = append(events, evnt)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
 func (r *repository) UpdateIceTypesNum(ctx context.Context, onlyOneIceType bool, eventID string) error {
 	q := `
 	UPDATE events
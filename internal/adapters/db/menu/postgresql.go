@@ -488,7 +488,8 @@ func (r *repository) AddDrink(ctx context.Context, dto menu.AddDrinkDTO) (string
 	return drinkID, nil
 }
 
-func (r *repository) DeleteDrink(ctx context.Context, dto menu.DeleteDrinkDTO) error {
+// Возвращает id меню, из которого удален напиток
+func (r *repository) DeleteDrink(ctx context.Context, dto menu.DeleteDrinkDTO) (string, error) {
 	tx, err := r.client.Begin(ctx)
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -496,10 +497,10 @@ func (r *repository) DeleteDrink(ctx context.Context, dto menu.DeleteDrinkDTO) e
 			pgErr = err.(*pgconn.PgError)
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
 			r.logger.Error(newErr)
-			return newErr
+			return "", newErr
 		}
 
-		return err
+		return "", err
 	}
 
 	q := `
@@ -524,10 +525,10 @@ func (r *repository) DeleteDrink(ctx context.Context, dto menu.DeleteDrinkDTO) e
 			pgErr = err.(*pgconn.PgError)
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
 			r.logger.Error(newErr)
-			return newErr
+			return "", newErr
 		}
 
-		return err
+		return "", err
 	}
 
 	q = `
@@ -549,10 +550,10 @@ func (r *repository) DeleteDrink(ctx context.Context, dto menu.DeleteDrinkDTO) e
 			pgErr = err.(*pgconn.PgError)
 			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
 			r.logger.Error(newErr)
-			return newErr
+			return "", newErr
 		}
 
-		return err
+		return "", err
 	}
 
 	if ct.String() != "UPDATE 1" {
@@ -560,13 +561,13 @@ func (r *repository) DeleteDrink(ctx context.Context, dto menu.DeleteDrinkDTO) e
 		tx.Conn().Close(ctx)
 
 		err := fmt.Errorf("database updating error: menu not found")
-		return err
+		return "", err
 	}
 
 	tx.Commit(ctx)
 	tx.Conn().Close(ctx)
 
-	return nil
+	return menuID, nil
 }
 
 func (r *repository) FindUserDrink(ctx context.Context, drID string) (menu.NewDrinkDTO, error) {
@@ -1 +1,524 @@
-package order
+package order_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi/internal/apperror"
+	"restapi/internal/domain/order"
+	"restapi/internal/domain/tab"
+	"restapi/pkg/client/postgresql"
+	"restapi/pkg/logging"
+	repeatable "restapi/pkg/utils"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// История статусов заказа собирается одним подзапросом в json-массив в порядке номеров записей
+const historyColumn = `
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'from', h.from_status, 'to', h.to_status,
+			'changed_by', h.changed_by, 'changed_at', h.changed_at, 'seq', h.seq) ORDER BY h.seq)
+		FROM order_status_history h
+		WHERE h.order_id = o.id
+	), '[]')`
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+// Номера записей истории заказов выдаются счетчиком bars.last_seq.
+// Строка бара блокируется до конца запроса, поэтому номера в баре идут подряд
+// и в том же порядке, в котором записи становятся видны
+const nextSeqQuery = `
+		UPDATE bars
+		SET
+			last_seq = last_seq + 1
+		WHERE
+			id = %s
+		RETURNING
+			id, event_id, last_seq`
+
+// event_id заказа берется из бара, в который он сделан.
+// Если указан счет, заказ создается только вместе с начислением на него:
+// счет должен быть открыт, относиться к ивенту бара и совпадать по секрету.
+// Строка счета блокируется, чтобы его не закрыли до начисления
+func (r *repository) CreateOrder(ctx context.Context, dto order.CreateOrderDTO, total uint32) (order.Order, error) {
+	q := fmt.Sprintf(`
+	WITH tb AS (
+		SELECT
+			t.id
+		FROM
+			tabs t
+		WHERE
+			t.id = NULLIF($6::text, '')::uuid AND t.secret = $7 AND t.status = $8
+			AND t.event_id = (SELECT event_id FROM bars WHERE id = $1)
+		FOR UPDATE
+	), seq AS (%s
+	), new_order AS (
+		INSERT INTO orders
+			(event_id, bar_id, tab_id, items, comment, total, status, created_at, updated_at)
+		SELECT
+			event_id, id, (SELECT id FROM tb), $2, $3, $4, $5, now(), now()
+		FROM
+			seq
+		RETURNING
+			id, event_id, bar_id, tab_id, items, comment, total, status, created_at, updated_at
+	), charge AS (
+		INSERT INTO tab_charges
+			(tab_id, order_id, amount, charged_at)
+		SELECT
+			tab_id, id, total, created_at
+		FROM
+			new_order
+		WHERE
+			tab_id IS NOT NULL
+	), placed AS (
+		INSERT INTO order_status_history
+			(order_id, from_status, to_status, changed_by, changed_at, seq)
+		SELECT
+			o.id, '', o.status, '', o.created_at, s.last_seq
+		FROM
+			new_order o, seq s
+	)
+	SELECT
+		o.id, o.event_id, o.bar_id, COALESCE(o.tab_id::text, ''), o.items, o.comment, o.total, o.status,
+		o.created_at, o.updated_at, s.last_seq
+	FROM
+		new_order o, seq s
+	`, fmt.Sprintf(nextSeqQuery, "$1 AND ($6::text = '' OR EXISTS (SELECT 1 FROM tb))"))
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var (
+		ordr order.Order
+		seq  uint64
+	)
+
+	err := r.client.QueryRow(ctx, q, dto.BarID, dto.Items, dto.Comment, total, order.StatusPlaced, dto.TabID,
+		dto.TabSecret, tab.StatusOpen).Scan(
+		&ordr.ID, &ordr.EventID, &ordr.BarID, &ordr.TabID, &ordr.Items, &ordr.Comment, &ordr.Total, &ordr.Status,
+		&ordr.CreatedAt, &ordr.UpdatedAt, &seq)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if dto.TabID != "" {
+				return order.Order{}, fmt.Errorf("database inserting error: bar %d or open tab %s not found", dto.BarID, dto.TabID)
+			}
+			return order.Order{}, fmt.Errorf("database inserting error: bar %d not found", dto.BarID)
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return order.Order{}, newErr
+		}
+
+		return order.Order{}, err
+	}
+
+	ordr.History = []order.StatusChange{{
+		To:        ordr.Status,
+		ChangedAt: ordr.CreatedAt,
+		Seq:       seq,
+	}}
+
+	return ordr, nil
+}
+
+func (r *repository) FindOrder(ctx context.Context, dto order.FindOrderDTO) (order.Order, error) {
+	q := fmt.Sprintf(`
+	SELECT
+    	o.id, o.event_id, o.bar_id, COALESCE(o.tab_id::text, ''), o.items, o.comment, o.total, o.status, %s, o.created_at, o.updated_at
+	FROM
+    	orders o
+	WHERE
+    	o.id = $1
+	`, historyColumn)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var ordr order.Order
+
+	err := r.client.QueryRow(ctx, q, dto.ID).Scan(&ordr.ID, &ordr.EventID, &ordr.BarID, &ordr.TabID,
+		&ordr.Items, &ordr.Comment, &ordr.Total, &ordr.Status, &ordr.History, &ordr.CreatedAt, &ordr.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return order.Order{}, fmt.Errorf("database searching error: order %s %w", dto.ID, apperror.ErrNotFound)
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return order.Order{}, newErr
+		}
+
+		return order.Order{}, err
+	}
+
+	return ordr, nil
+}
+
+func (r *repository) FindBarOrders(ctx context.Context, dto order.FindBarOrdersDTO) ([]order.Order, error) {
+	q := fmt.Sprintf(`
+	SELECT
+    	o.id, o.event_id, o.bar_id, COALESCE(o.tab_id::text, ''), o.items, o.comment, o.total, o.status, %s, o.created_at, o.updated_at
+	FROM
+    	orders o
+	WHERE
+    	o.bar_id = $1 AND o.event_id = $2
+	ORDER BY o.created_at ASC
+	`, historyColumn)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.BarID, dto.EventID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return nil, newErr
+		}
+
+		return nil, err
+	}
+
+	return r.scanOrders(rows)
+}
+
+func (r *repository) FindEventOrders(ctx context.Context, dto order.FindEventOrdersDTO) ([]order.Order, error) {
+	q := fmt.Sprintf(`
+	SELECT
+    	o.id, o.event_id, o.bar_id, COALESCE(o.tab_id::text, ''), o.items, o.comment, o.total, o.status, %s, o.created_at, o.updated_at
+	FROM
+    	orders o
+	WHERE
+    	o.event_id = $1
+	ORDER BY o.created_at ASC
+	`, historyColumn)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.EventID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return nil, newErr
+		}
+
+		return nil, err
+	}
+
+	return r.scanOrders(rows)
+}
+
+// EachEventOrder по очереди передает заказы ивента в fn, не собирая их в память.
+// Ошибка fn прерывает чтение
+func (r *repository) EachEventOrder(ctx context.Context, dto order.FindEventOrdersDTO, fn func(order.Order) error) error {
+	q := fmt.Sprintf(`
+	SELECT
+    	o.id, o.event_id, o.bar_id, COALESCE(o.tab_id::text, ''), o.items, o.comment, o.total, o.status, %s, o.created_at, o.updated_at
+	FROM
+    	orders o
+	WHERE
+    	o.event_id = $1
+	ORDER BY o.created_at ASC
+	`, historyColumn)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.EventID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return newErr
+		}
+
+		return err
+	}
+
+	return r.eachOrder(rows, fn)
+}
+
+// Статус меняется только если заказ все еще находится в статусе change.From,
+// поэтому одновременные изменения одного заказа не перезаписывают друг друга.
+// Если заказ отменяется, начисление за него снимается со счета в том же запросе,
+// и только пока счет открыт. Строка счета блокируется, чтобы его не закрыли одновременно.
+// Возвращает номер новой записи истории в баре заказа
+func (r *repository) UpdateStatus(ctx context.Context, orderID string, change order.StatusChange) (uint64, error) {
+	q := fmt.Sprintf(`
+	WITH tb AS (
+		SELECT
+			t.id, t.status
+		FROM
+			tabs t
+		JOIN
+			orders o ON o.tab_id = t.id
+		WHERE
+			o.id = $1 AND $6
+		FOR UPDATE OF t
+	), upd AS (
+		UPDATE orders
+		SET
+			status = $3, updated_at = $5
+		WHERE
+			id = $1 AND status = $2
+			AND (NOT $6 OR tab_id IS NULL OR tab_id IN (SELECT id FROM tb WHERE status = $7))
+		RETURNING
+			id, bar_id
+	), refund AS (
+		DELETE FROM
+			tab_charges
+		WHERE
+			$6 AND order_id IN (SELECT id FROM upd)
+	), seq AS (%s
+	)
+	INSERT INTO order_status_history
+		(order_id, from_status, to_status, changed_by, changed_at, seq)
+	SELECT
+		u.id, $2, $3, $4, $5, s.last_seq
+	FROM
+		upd u, seq s
+	RETURNING
+		seq
+	`, fmt.Sprintf(nextSeqQuery, "(SELECT bar_id FROM upd)"))
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var seq uint64
+
+	err := r.client.QueryRow(ctx, q, orderID, change.From, change.To, change.ChangedBy, change.ChangedAt,
+		order.IsRefunded(change.To), tab.StatusOpen).Scan(&seq)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("database updating error: order not found, its status was already changed or its tab is closed")
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return 0, newErr
+		}
+
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+// Каждая запись возвращается вместе с заказом в том статусе, который она установила.
+// История такого заказа состоит только из этой записи
+func (r *repository) FindBarHistory(ctx context.Context, dto order.FindBarHistoryDTO) ([]order.HistoryEvent, error) {
+	q := `
+	SELECT
+		h.from_status, h.to_status, h.changed_by, h.changed_at, h.seq,
+		o.id, o.event_id, o.bar_id, COALESCE(o.tab_id::text, ''), o.items, o.comment, o.total, o.created_at
+	FROM
+		order_status_history h
+	JOIN
+		orders o ON o.id = h.order_id
+	WHERE
+		o.bar_id = $1 AND o.event_id = $2 AND h.seq > $3
+	ORDER BY h.seq ASC
+	LIMIT $4
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.BarID, dto.EventID, dto.AfterSeq, dto.Limit)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return nil, newErr
+		}
+
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]order.HistoryEvent, 0)
+
+	for rows.Next() {
+		var ev order.HistoryEvent
+
+		err = rows.Scan(&ev.Change.From, &ev.Change.To, &ev.Change.ChangedBy, &ev.Change.ChangedAt, &ev.Change.Seq,
+			&ev.Order.ID, &ev.Order.EventID, &ev.Order.BarID, &ev.Order.TabID, &ev.Order.Items, &ev.Order.Comment,
+			&ev.Order.Total, &ev.Order.CreatedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				r.logger.Error(newErr)
+				return nil, newErr
+			}
+
+			return nil, err
+		}
+
+		ev.Order.Status = ev.Change.To
+		ev.Order.UpdatedAt = ev.Change.ChangedAt
+		ev.Order.History = []order.StatusChange{ev.Change}
+
+		events = append(events, ev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *repository) FindOrderBar(ctx context.Context, barID uint32) (order.OrderBar, error) {
+	q := `
+	SELECT
+		b.id, b.event_id, e.menu_id, b.status,
+		COALESCE((SELECT array_agg(u.drink_id) FROM bar_unavailable_drinks u WHERE u.bar_id = b.id), '{}')
+	FROM
+		bars b
+	JOIN
+		events e ON e.id = b.event_id
+	WHERE
+		b.id = $1
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var ordrBar order.OrderBar
+
+	err := r.client.QueryRow(ctx, q, barID).Scan(&ordrBar.BarID, &ordrBar.EventID, &ordrBar.MenuID, &ordrBar.BarStatus,
+		&ordrBar.UnavailableDrinks)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return order.OrderBar{}, fmt.Errorf("database searching error: bar %d %w", barID, apperror.ErrNotFound)
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return order.OrderBar{}, newErr
+		}
+
+		return order.OrderBar{}, err
+	}
+
+	return ordrBar, nil
+}
+
+// Загрузка бара считается одним запросом по заказам и записям о подаче,
+// без загрузки всей истории заказов. Открытые заказы возвращаются от самых старых,
+// не больше dto.Slowest (все, если dto.Slowest отрицательный)
+func (r *repository) FindBarLoad(ctx context.Context, dto order.FindThroughputDTO, since time.Time) (order.BarLoad, error) {
+	q := `
+	SELECT
+		count(*) FILTER (WHERE o.created_at > $3),
+		count(h.order_id),
+		COALESCE(sum(EXTRACT(EPOCH FROM h.changed_at - o.created_at)), 0)::float8,
+		count(*) FILTER (WHERE o.status = ANY($4)),
+		COALESCE((
+			SELECT json_agg(json_build_object(
+				'id', s.id, 'status', s.status, 'created_at', s.created_at) ORDER BY s.created_at)
+			FROM (
+				SELECT id, status, created_at
+				FROM orders
+				WHERE bar_id = $1 AND event_id = $2 AND status = ANY($4)
+				ORDER BY created_at ASC
+				LIMIT $6
+			) s
+		), '[]')
+	FROM
+		orders o
+	LEFT JOIN
+		order_status_history h ON h.order_id = o.id AND h.to_status = $5 AND h.changed_at > $3
+	WHERE
+		o.bar_id = $1 AND o.event_id = $2
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var limit *int
+	if dto.Slowest >= 0 {
+		limit = &dto.Slowest
+	}
+
+	var load order.BarLoad
+
+	err := r.client.QueryRow(ctx, q, dto.BarID, dto.EventID, since, order.OpenStatuses(), order.StatusServed,
+		limit).Scan(&load.Placed, &load.Served, &load.ServeSeconds, &load.QueueLength, &load.Open)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return order.BarLoad{}, newErr
+		}
+
+		return order.BarLoad{}, err
+	}
+
+	return load, nil
+}
+
+func (r *repository) scanOrders(rows pgx.Rows) ([]order.Order, error) {
+	orders := make([]order.Order, 0)
+
+	err := r.eachOrder(rows, func(ordr order.Order) error {
+		orders = append(orders, ordr)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (r *repository) eachOrder(rows pgx.Rows, fn func(order.Order) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var ordr order.Order
+
+		err := rows.Scan(&ordr.ID, &ordr.EventID, &ordr.BarID, &ordr.TabID, &ordr.Items, &ordr.Comment, &ordr.Total,
+			&ordr.Status, &ordr.History, &ordr.CreatedAt, &ordr.UpdatedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				r.logger.Error(newErr)
+				return newErr
+			}
+
+			return err
+		}
+
+		if err := fn(ordr); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) order.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
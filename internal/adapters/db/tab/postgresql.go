@@ -0,0 +1,252 @@
+package tab_db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"restapi/internal/apperror"
+	"restapi/internal/domain/tab"
+	"restapi/pkg/client/postgresql"
+	"restapi/pkg/logging"
+	repeatable "restapi/pkg/utils"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Начисления счета собираются одним подзапросом в json-массив
+const chargesColumn = `
+	COALESCE((
+		SELECT json_agg(json_build_object(
+			'order_id', c.order_id, 'amount', c.amount, 'charged_at', c.charged_at) ORDER BY c.charged_at)
+		FROM tab_charges c
+		WHERE c.tab_id = t.id
+	), '[]')`
+
+type repository struct {
+	client postgresql.Client
+	logger *logging.Logger
+}
+
+func (r *repository) CreateTab(ctx context.Context, dto tab.OpenTabDTO, secret string) (tab.Tab, error) {
+	q := `
+	INSERT INTO tabs
+    	(event_id, guest_name, status, secret, opened_at)
+	VALUES
+    	($1, $2, $3, $4, now())
+	RETURNING
+    	id, opened_at
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	tb := tab.Tab{
+		EventID:   dto.EventID,
+		GuestName: dto.GuestName,
+		Secret:    secret,
+		Status:    tab.StatusOpen,
+		Charges:   make([]tab.Charge, 0),
+	}
+
+	err := r.client.QueryRow(ctx, q, dto.EventID, dto.GuestName, tab.StatusOpen, secret).Scan(&tb.ID, &tb.OpenedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return tab.Tab{}, newErr
+		}
+
+		return tab.Tab{}, err
+	}
+
+	return tb, nil
+}
+
+func (r *repository) FindTab(ctx context.Context, dto tab.FindTabDTO) (tab.Tab, error) {
+	q := fmt.Sprintf(`
+	SELECT
+    	t.id, t.event_id, t.guest_name, t.status, %s, COALESCE(t.shares, '[]'), t.opened_at, t.closed_at
+	FROM
+    	tabs t
+	WHERE
+    	t.id = $1 AND ($2 = '' OR t.secret = $2)
+	`, chargesColumn)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var tb tab.Tab
+
+	err := r.client.QueryRow(ctx, q, dto.ID, dto.Secret).Scan(&tb.ID, &tb.EventID, &tb.GuestName, &tb.Status,
+		&tb.Charges, &tb.Shares, &tb.OpenedAt, &tb.ClosedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return tab.Tab{}, fmt.Errorf("database searching error: tab %s %w", dto.ID, apperror.ErrNotFound)
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return tab.Tab{}, newErr
+		}
+
+		return tab.Tab{}, err
+	}
+
+	tb.Total = tb.CountTotal()
+
+	return tb, nil
+}
+
+func (r *repository) FindEventTabs(ctx context.Context, dto tab.FindEventTabsDTO) ([]tab.Tab, error) {
+	q := fmt.Sprintf(`
+	SELECT
+    	t.id, t.event_id, t.guest_name, t.status, %s, COALESCE(t.shares, '[]'), t.opened_at, t.closed_at
+	FROM
+    	tabs t
+	WHERE
+    	t.event_id = $1
+	ORDER BY t.opened_at ASC
+	`, chargesColumn)
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	rows, err := r.client.Query(ctx, q, dto.EventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tabs := make([]tab.Tab, 0)
+
+	for rows.Next() {
+		var tb tab.Tab
+
+		err = rows.Scan(&tb.ID, &tb.EventID, &tb.GuestName, &tb.Status,
+			&tb.Charges, &tb.Shares, &tb.OpenedAt, &tb.ClosedAt)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				pgErr = err.(*pgconn.PgError)
+				newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+				r.logger.Error(newErr)
+				return nil, newErr
+			}
+
+			return nil, err
+		}
+
+		tb.Total = tb.CountTotal()
+
+		tabs = append(tabs, tb)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tabs, nil
+}
+
+// Закрывает открытый счет и возвращает его итоговую сумму.
+// Заказ начисляется на счет только под блокировкой открытого счета, поэтому после закрытия
+// начисления не меняются. Сумма читается отдельным запросом уже после закрытия:
+// подзапрос в RETURNING видит снимок до ожидания блокировки и может пропустить начисление
+// заказа, который держал счет в этот момент
+func (r *repository) CloseTab(ctx context.Context, tabID string, closedAt time.Time) (uint32, error) {
+	q := `
+	UPDATE tabs
+	SET
+		status = $2, closed_at = $3
+	WHERE
+		id = $1 AND status = $4
+	RETURNING
+		id
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var id string
+
+	err := r.client.QueryRow(ctx, q, tabID, tab.StatusClosed, closedAt, tab.StatusOpen).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("database updating error: open tab not found")
+		}
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return 0, newErr
+		}
+
+		return 0, err
+	}
+
+	q = `
+	SELECT
+		COALESCE(sum(amount), 0)::bigint
+	FROM
+		tab_charges
+	WHERE
+		tab_id = $1
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	var total uint32
+
+	err = r.client.QueryRow(ctx, q, id).Scan(&total)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return 0, newErr
+		}
+
+		return 0, err
+	}
+
+	return total, nil
+}
+
+func (r *repository) SetShares(ctx context.Context, tabID string, shares []tab.Share) error {
+	q := `
+	UPDATE tabs
+	SET
+		shares = $2
+	WHERE
+		id = $1 AND status = $3
+	`
+	r.logger.Trace(fmt.Sprintf("SQL query: %s", repeatable.FormatQuery(q)))
+
+	ct, err := r.client.Exec(ctx, q, tabID, shares, tab.StatusClosed)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			pgErr = err.(*pgconn.PgError)
+			newErr := fmt.Errorf(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s, Code: %s, SQLState: %s", pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code, pgErr.SQLState()))
+			r.logger.Error(newErr)
+			return newErr
+		}
+
+		return err
+	}
+
+	if ct.String() != "UPDATE 1" {
+		err := fmt.Errorf("database updating error: closed tab not found")
+		return err
+	}
+
+	return nil
+}
+
+func NewRepository(client postgresql.Client, logger *logging.Logger) tab.Repository {
+	return &repository{
+		client: client,
+		logger: logger,
+	}
+}
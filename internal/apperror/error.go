@@ -6,6 +6,7 @@ var (
 	ErrNotFound     = NewAppError(nil, "not found", "", "US-000003")
 	ErrNoContent    = NewAppError(nil, "no content", "", "US-000004")
 	ErrUnauthorized = NewAppError(nil, "not authorised", "", "US-000005")
+	ErrForbidden    = NewAppError(nil, "access denied", "", "US-000006")
 )
 
 type AppError struct {
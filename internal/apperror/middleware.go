@@ -26,6 +26,10 @@ func Middleware(h AppHandler) http.HandlerFunc {
 					w.WriteHeader(http.StatusUnauthorized)
 					w.Write(ErrUnauthorized.Marshal())
 					return
+				} else if errors.Is(err, ErrForbidden) {
+					w.WriteHeader(http.StatusForbidden)
+					w.Write(ErrForbidden.Marshal())
+					return
 				}
 
 				/* else if errors.Is(err, NoAuthErr) {
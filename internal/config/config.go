@@ -24,8 +24,10 @@ type Config struct {
 		Password   string `json:"password"`
 		Collection string `json:"collection"`
 	} `json:"mongodb"`
-	Storage StorageConfig `yaml:"storage"`
-	Tokens  TokenConfig   `yaml:"auth"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Tokens    TokenConfig     `yaml:"auth"`
+	WebSocket WebSocketConfig `yaml:"websocket"`
+	Event     EventConfig     `yaml:"event"`
 }
 
 type StorageConfig struct {
@@ -42,6 +44,23 @@ type TokenConfig struct {
 	SigningKey      string        `yaml:"signing_key"`
 }
 
+// Если AllowedOrigins пустой, принимаются только подключения с того же хоста.
+// JoinURL - адрес websocket-подключения, из которого строятся ссылки на сессии баров.
+// NotifyChannel - канал Postgres NOTIFY, через который экземпляры приложения обмениваются сообщениями хаба.
+// GuestPageURL - страница заказа, которую гость открывает по QR-коду бара
+type WebSocketConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	JoinURL        string   `yaml:"join_url" env-default:"ws://localhost:10000/api/bar/ws"`
+	NotifyChannel  string   `yaml:"notify_channel" env-default:"bar_hub"`
+	GuestPageURL   string   `yaml:"guest_page_url"`
+}
+
+// DrinksPerGuest - сколько напитков каждой категории меню в среднем выпивает гость,
+// по нему считается список покупок. Для категорий, которых нет в списке, берется 1
+type EventConfig struct {
+	DrinksPerGuest map[string]float64 `yaml:"drinks_per_guest"`
+}
+
 var instance *Config
 var once sync.Once
 
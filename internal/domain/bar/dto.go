@@ -7,7 +7,8 @@ type CreateBarDTO struct {
 }
 
 type RespCreateBar struct {
-	ID uint32 `json:"id"`
+	ID         uint32 `json:"id"`
+	SessionURL string `json:"session_url"`
 }
 
 type CloseBarDTO struct {
@@ -15,11 +16,9 @@ type CloseBarDTO struct {
 }
 
 type UpdateBarDTO struct {
-	ID          uint32   `json:"id"`
-	Name        string   `json:"name"`
-	Description string   `json:"info"`
-	Orders      []string `json:"orders"`
-	SessionURL  string   `json:"session_url"`
+	ID          uint32 `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"info"`
 }
 
 type GetOrdersDTO struct {
@@ -30,3 +29,52 @@ type GetBarOrdersDTO struct {
 	ID      uint32 `json:"id"`
 	EventID string `json:"event_id"`
 }
+
+type JoinSessionDTO struct {
+	Token string `json:"token"`
+}
+
+type FindBarDTO struct {
+	ID uint32 `json:"id"`
+}
+
+// Status - Opened или Closed, пустой - бары в любом статусе
+type FindEventBarsDTO struct {
+	EventID string `json:"event_id"`
+	Status  string `json:"status"`
+}
+
+type AssignStaffDTO struct {
+	BarID  uint32 `json:"bar_id"`
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type UnassignStaffDTO struct {
+	BarID  uint32 `json:"bar_id"`
+	UserID string `json:"user_id"`
+}
+
+type FindBarStaffDTO struct {
+	BarID uint32 `json:"bar_id"`
+}
+
+type FindStaffMemberDTO struct {
+	BarID  uint32 `json:"bar_id"`
+	UserID string `json:"user_id"`
+}
+
+type FindUserStaffDTO struct {
+	UserID string `json:"user_id"`
+}
+
+type SetDrinkAvailabilityDTO struct {
+	BarID     uint32 `json:"bar_id"`
+	DrinkID   string `json:"drink_id"`
+	Available bool   `json:"available"`
+	UserID    string `json:"-"`
+}
+
+type FindUnavailableDrinksDTO struct {
+	BarID uint32 `json:"bar_id"`
+}
@@ -1,11 +1,61 @@
 package bar
 
+import "time"
+
+const (
+	// bar statuses
+	StatusOpened = "Opened"
+	StatusClosed = "Closed"
+
+	// staff roles
+	StaffHeadBartender = "head_bartender"
+	StaffBartender     = "bartender"
+	StaffBarback       = "barback"
+)
+
+// SessionURL - ссылка для подключения к живой сессии бара по websocket.
+// Она создается при открытии бара и перестает работать после его закрытия
 type Bar struct {
-	ID          string   `json:"id"`
-	EventID     string   `json:"event_id"`
-	Name        string   `json:"name"`
-	Description string   `json:"info"`
-	Orders      []string `json:"orders"` // массив id-ов заказов
-	Status      string   `json:"status"`
-	SessionURL  string
+	ID           uint32 `json:"id"`
+	EventID      string `json:"event_id"`
+	Name         string `json:"name"`
+	Description  string `json:"info"`
+	Status       string `json:"status"`
+	SessionURL   string `json:"session_url"`
+	SessionToken string `json:"-"`
+}
+
+// Живые сессии баров, к которым подключаются клиенты.
+// CloseSession уведомляет подключенных клиентов о закрытии бара,
+// дожидается отправки их сообщений и отключает их
+type Sessions interface {
+	OpenSession(eventID string, barID uint32)
+	CloseSession(eventID string, barID uint32)
+}
+
+// Сотрудник бара - зарегистрированный пользователь, назначенный на бар организатором ивента.
+// У бара может быть только один главный бармен
+type Staff struct {
+	BarID      uint32    `json:"bar_id"`
+	UserID     string    `json:"user_id"`
+	Role       string    `json:"role"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+func IsStaffRole(role string) bool {
+	switch role {
+	case StaffHeadBartender, StaffBartender, StaffBarback:
+		return true
+	}
+	return false
+}
+
+// Напиток, который временно нельзя заказать в баре, хотя он есть в меню.
+// Хранятся только недоступные напитки, остальные напитки бара доступны
+type DrinkAvailability struct {
+	BarID     uint32    `json:"bar_id"`
+	DrinkID   string    `json:"drink_id"`
+	Available bool      `json:"available"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
 }
@@ -2,87 +2,346 @@ package bar
 
 import (
 	"context"
+	"crypto/rand"
+	"fmt"
+	"restapi/internal/domain/menu"
+	"restapi/internal/domain/order"
 	"restapi/pkg/logging"
 )
 
 type Service interface {
-	OpenBar(context.Context, CreateBarDTO) (uint32, error)
+	OpenBar(context.Context, CreateBarDTO) (Bar, error)
 	CloseBar(context.Context, CloseBarDTO) error
+	JoinSession(context.Context, JoinSessionDTO) (Bar, error)
+	FindBar(context.Context, FindBarDTO) (Bar, error)
+	FindEventBars(context.Context, FindEventBarsDTO) ([]Bar, error)
+	AssignStaff(context.Context, AssignStaffDTO) (Staff, error)
+	UnassignStaff(context.Context, UnassignStaffDTO) error
+	FindBarStaff(context.Context, FindBarStaffDTO) ([]Staff, error)
+	FindStaffMember(context.Context, FindStaffMemberDTO) (Staff, error)
+	FindUserStaff(context.Context, FindUserStaffDTO) ([]Staff, error)
+	SetDrinkAvailability(context.Context, SetDrinkAvailabilityDTO) (DrinkAvailability, error)
+	FindUnavailableDrinks(context.Context, FindUnavailableDrinksDTO) ([]DrinkAvailability, error)
 	UpdateInfo(context.Context, UpdateBarDTO) error
-	GetOrders(context.Context, GetOrdersDTO) ([]string, error)
-	GetBarOrders(context.Context, GetBarOrdersDTO) ([]string, error)
+	GetOrders(context.Context, GetOrdersDTO) ([]order.Order, error)
+	GetBarOrders(context.Context, GetBarOrdersDTO) ([]order.Order, error)
 }
 
 type service struct {
 	repository Repository
+	orderRepos order.Repository
+	menuRepos  menu.Repository
+	sessions   Sessions
+	joinURL    string
 	logger     *logging.Logger
 }
 
-func NewService(repository Repository, logger *logging.Logger) Service {
+// joinURL - адрес websocket-подключения, к которому добавляется токен сессии бара
+func NewService(repository Repository, orderRepos order.Repository, menuRepos menu.Repository,
+	sessions Sessions, joinURL string, logger *logging.Logger) Service {
 	return &service{
 		repository: repository,
+		orderRepos: orderRepos,
+		menuRepos:  menuRepos,
+		sessions:   sessions,
+		joinURL:    joinURL,
 		logger:     logger,
 	}
 }
 
-func (s *service) OpenBar(ctx context.Context, dto CreateBarDTO) (uint32, error) {
+// OpenBar создает бар вместе с его живой сессией и ссылкой для подключения к ней
+func (s *service) OpenBar(ctx context.Context, dto CreateBarDTO) (Bar, error) {
 	s.logger.Infof("creating bar")
 
-	barID, err := s.repository.CreateBar(ctx, dto)
+	token, err := newSessionToken()
+	if err != nil {
+		return Bar{}, fmt.Errorf("generating session token error: %v", err)
+	}
+
+	sessionURL := fmt.Sprintf("%s?session=%s", s.joinURL, token)
+
+	barID, err := s.repository.CreateBar(ctx, dto, token, sessionURL)
 
 	if err != nil {
-		return 0, err
+		return Bar{}, err
 	}
 
+	s.sessions.OpenSession(dto.EventID, barID)
+
 	s.logger.Infof("bar is created, bar_id: %d", barID)
 
-	return barID, nil
+	return Bar{
+		ID:           barID,
+		EventID:      dto.EventID,
+		Name:         dto.Name,
+		Description:  dto.Description,
+		Status:       StatusOpened,
+		SessionURL:   sessionURL,
+		SessionToken: token,
+	}, nil
 }
 
+// CloseBar закрывает бар и завершает его сессию: подключенные клиенты получают
+// уведомление о закрытии и отключаются, а ссылка для подключения перестает работать
 func (s *service) CloseBar(ctx context.Context, dto CloseBarDTO) error {
 	s.logger.Infof("closing bar %d", dto.ID)
 
-	err := s.repository.CloseBar(ctx, dto)
+	br, err := s.repository.CloseBar(ctx, dto)
 
 	if err != nil {
 		return err
 	}
 
+	s.sessions.CloseSession(br.EventID, br.ID)
+
 	s.logger.Infof("bar is closed, bar_id: %d", dto.ID)
 
 	return nil
 }
 
-func (s *service) GetOrders(ctx context.Context, dto GetOrdersDTO) ([]string, error) {
+// JoinSession возвращает открытый бар, к сессии которого ведет токен
+func (s *service) JoinSession(ctx context.Context, dto JoinSessionDTO) (Bar, error) {
+	s.logger.Infof("joining bar session")
+
+	br, err := s.repository.FindBySession(ctx, dto.Token)
+	if err != nil {
+		return Bar{}, fmt.Errorf("bar session not found")
+	}
+
+	if br.Status != StatusOpened {
+		return Bar{}, fmt.Errorf("bar %d is closed", br.ID)
+	}
+
+	return br, nil
+}
+
+func newSessionToken() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", b), nil
+}
+
+func (s *service) FindBar(ctx context.Context, dto FindBarDTO) (Bar, error) {
+	s.logger.Infof("find bar, bar_id: %d", dto.ID)
+
+	br, err := s.repository.FindBar(ctx, dto)
+
+	if err != nil {
+		return Bar{}, err
+	}
+
+	s.logger.Infof("bar is found")
+
+	return br, nil
+}
+
+// FindEventBars возвращает бары ивента, если dto.Status не пустой - только бары в этом статусе
+func (s *service) FindEventBars(ctx context.Context, dto FindEventBarsDTO) ([]Bar, error) {
+	s.logger.Infof("find event bars, event_id: %s, status: %s", dto.EventID, dto.Status)
+
+	if dto.Status != "" && dto.Status != StatusOpened && dto.Status != StatusClosed {
+		return nil, fmt.Errorf("unknown bar status: %s", dto.Status)
+	}
+
+	bars, err := s.repository.FindEventBars(ctx, dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("event bars are found")
+	s.logger.Tracef("bars count: %d", len(bars))
+
+	return bars, nil
+}
+
+// AssignStaff назначает пользователя на бар или меняет его роль, если он уже назначен.
+// Главного бармена можно назначить, только если у бара его еще нет
+func (s *service) AssignStaff(ctx context.Context, dto AssignStaffDTO) (Staff, error) {
+	s.logger.Infof("assigning user %s to bar %d as %s", dto.UserID, dto.BarID, dto.Role)
+
+	if !IsStaffRole(dto.Role) {
+		return Staff{}, fmt.Errorf("unknown staff role: %s", dto.Role)
+	}
+
+	br, err := s.repository.FindBar(ctx, FindBarDTO{ID: dto.BarID})
+	if err != nil {
+		return Staff{}, fmt.Errorf("finding bar error: %w", err)
+	}
+
+	if br.Status == StatusClosed {
+		return Staff{}, fmt.Errorf("bar %d is closed", br.ID)
+	}
+
+	member, err := s.repository.AssignStaff(ctx, dto)
+
+	if err != nil {
+		return Staff{}, err
+	}
+
+	s.logger.Infof("user %s is assigned to bar %d", dto.UserID, dto.BarID)
+
+	return member, nil
+}
+
+func (s *service) UnassignStaff(ctx context.Context, dto UnassignStaffDTO) error {
+	s.logger.Infof("unassigning user %s from bar %d", dto.UserID, dto.BarID)
+
+	err := s.repository.UnassignStaff(ctx, dto)
+
+	if err != nil {
+		return err
+	}
+
+	s.logger.Infof("user %s is unassigned from bar %d", dto.UserID, dto.BarID)
+
+	return nil
+}
+
+func (s *service) FindBarStaff(ctx context.Context, dto FindBarStaffDTO) ([]Staff, error) {
+	s.logger.Infof("find bar staff, bar_id: %d", dto.BarID)
+
+	staff, err := s.repository.FindBarStaff(ctx, dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Tracef("staff count: %d", len(staff))
+
+	return staff, nil
+}
+
+// FindStaffMember возвращает назначение пользователя на бар или ошибку,
+// если пользователь не работает в этом баре
+func (s *service) FindStaffMember(ctx context.Context, dto FindStaffMemberDTO) (Staff, error) {
+	s.logger.Infof("find staff member, bar_id: %d, user_id: %s", dto.BarID, dto.UserID)
+
+	member, err := s.repository.FindStaffMember(ctx, dto)
+
+	if err != nil {
+		return Staff{}, err
+	}
+
+	return member, nil
+}
+
+// FindUserStaff возвращает все бары, на которые назначен пользователь
+func (s *service) FindUserStaff(ctx context.Context, dto FindUserStaffDTO) ([]Staff, error) {
+	s.logger.Infof("find user bars, user_id: %s", dto.UserID)
+
+	staff, err := s.repository.FindUserStaff(ctx, dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Tracef("bars count: %d", len(staff))
+
+	return staff, nil
+}
+
+// SetDrinkAvailability отмечает, можно ли сейчас заказать напиток в баре.
+// Напиток должен быть в меню ивента и подаваться в этом баре
+func (s *service) SetDrinkAvailability(ctx context.Context, dto SetDrinkAvailabilityDTO) (DrinkAvailability, error) {
+	s.logger.Infof("set drink %s availability at bar %d: %t", dto.DrinkID, dto.BarID, dto.Available)
+
+	ordrBar, err := s.orderRepos.FindOrderBar(ctx, dto.BarID)
+	if err != nil {
+		return DrinkAvailability{}, fmt.Errorf("finding bar error: %w", err)
+	}
+
+	if ordrBar.BarStatus == StatusClosed {
+		return DrinkAvailability{}, fmt.Errorf("bar %d is closed", dto.BarID)
+	}
+
+	mn, err := s.menuRepos.FindMenu(ctx, menu.FindMenuDTO{ID: ordrBar.MenuID})
+	if err != nil {
+		return DrinkAvailability{}, fmt.Errorf("finding menu error: %v", err)
+	}
+
+	if !servesDrink(mn, dto.BarID, dto.DrinkID) {
+		return DrinkAvailability{}, fmt.Errorf("drink %s is not served at bar %d", dto.DrinkID, dto.BarID)
+	}
+
+	availability, err := s.repository.SetDrinkAvailability(ctx, dto)
+
+	if err != nil {
+		return DrinkAvailability{}, err
+	}
+
+	s.logger.Infof("drink %s availability at bar %d is changed by user %s", dto.DrinkID, dto.BarID, dto.UserID)
+
+	return availability, nil
+}
+
+func (s *service) FindUnavailableDrinks(ctx context.Context, dto FindUnavailableDrinksDTO) ([]DrinkAvailability, error) {
+	s.logger.Infof("find unavailable drinks, bar_id: %d", dto.BarID)
+
+	drinks, err := s.repository.FindUnavailableDrinks(ctx, dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Tracef("unavailable drinks count: %d", len(drinks))
+
+	return drinks, nil
+}
+
+func servesDrink(mn menu.Menu, barID uint32, drinkID string) bool {
+	for _, group := range mn.Drinks {
+		for _, drink := range group {
+			if drink.ID != drinkID {
+				continue
+			}
+
+			for _, id := range drink.BarsID {
+				if id == barID {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *service) GetOrders(ctx context.Context, dto GetOrdersDTO) ([]order.Order, error) {
 	s.logger.Infof("find orders from all event bars, event_id: %s", dto.EventID)
 
-	ordersID, err := s.repository.GetOrders(ctx, dto)
+	orders, err := s.orderRepos.FindEventOrders(ctx, order.FindEventOrdersDTO{EventID: dto.EventID})
 
 	if err != nil {
 		return nil, err
 	}
 
 	s.logger.Infof("all event orders is found")
-	for n, id := range ordersID {
-		s.logger.Tracef("\n%d event name: %s", n, id)
+	for n, ordr := range orders {
+		s.logger.Tracef("\n%d order id: %s, bar_id: %d", n, ordr.ID, ordr.BarID)
 	}
 
-	return ordersID, nil
+	return orders, nil
 }
 
-func (s *service) GetBarOrders(ctx context.Context, dto GetBarOrdersDTO) ([]string, error) {
+func (s *service) GetBarOrders(ctx context.Context, dto GetBarOrdersDTO) ([]order.Order, error) {
 	s.logger.Infof("find one bar orders, event_id: %s, bar_id: %d", dto.EventID, dto.ID)
 
-	ordersID, err := s.repository.GetBarOrders(ctx, dto)
+	orders, err := s.orderRepos.FindBarOrders(ctx, order.FindBarOrdersDTO{BarID: dto.ID, EventID: dto.EventID})
 
 	if err != nil {
 		return nil, err
 	}
 
 	s.logger.Infof("event orders is found")
-	s.logger.Tracef("orders id: %s", ordersID)
+	s.logger.Tracef("orders count: %d", len(orders))
 
-	return ordersID, nil
+	return orders, nil
 }
 
 func (s *service) UpdateInfo(ctx context.Context, dto UpdateBarDTO) error {
@@ -94,7 +353,7 @@ func (s *service) UpdateInfo(ctx context.Context, dto UpdateBarDTO) error {
 		return err
 	}
 
-	s.logger.Infof("bar %s is updated", dto.ID)
+	s.logger.Infof("bar %d is updated", dto.ID)
 
 	return nil
 }
@@ -3,9 +3,17 @@ package bar
 import "context"
 
 type Repository interface {
-	CreateBar(context.Context, CreateBarDTO) (uint32, error)
-	CloseBar(context.Context, CloseBarDTO) error
+	CreateBar(context.Context, CreateBarDTO, string, string) (uint32, error)
+	FindBySession(context.Context, string) (Bar, error)
+	CloseBar(context.Context, CloseBarDTO) (Bar, error)
 	UpdateInfo(context.Context, UpdateBarDTO) error
-	GetOrders(context.Context, GetOrdersDTO) ([]string, error)
-	GetBarOrders(context.Context, GetBarOrdersDTO) ([]string, error)
+	FindBar(context.Context, FindBarDTO) (Bar, error)
+	FindEventBars(context.Context, FindEventBarsDTO) ([]Bar, error)
+	AssignStaff(context.Context, AssignStaffDTO) (Staff, error)
+	UnassignStaff(context.Context, UnassignStaffDTO) error
+	FindBarStaff(context.Context, FindBarStaffDTO) ([]Staff, error)
+	FindStaffMember(context.Context, FindStaffMemberDTO) (Staff, error)
+	FindUserStaff(context.Context, FindUserStaffDTO) ([]Staff, error)
+	SetDrinkAvailability(context.Context, SetDrinkAvailabilityDTO) (DrinkAvailability, error)
+	FindUnavailableDrinks(context.Context, FindUnavailableDrinksDTO) ([]DrinkAvailability, error)
 }
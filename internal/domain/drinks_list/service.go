@@ -29,6 +29,10 @@ func NewService(repository Repository, logger *logging.Logger) Service {
 func (s *service) AddUserDrink(ctx context.Context, dto AddUserDrinkDTO) (menu.Drink, error) {
 	s.logger.Infof("adding drink to drink list")
 
+	if err := dto.Composition.Normalize(); err != nil {
+		return menu.Drink{}, err
+	}
+
 	drinkID, err := s.repository.AddUserDrink(ctx, dto)
 
 	if err != nil {
@@ -96,6 +100,10 @@ func (s *service) FindUserDrinks(ctx context.Context, dto FindUserDrinksDTO) (Re
 func (s *service) UpdateUserDrink(ctx context.Context, dto UpdateUserDrinkDTO) error {
 	s.logger.Infof("update user drink")
 
+	if err := dto.Composition.Normalize(); err != nil {
+		return err
+	}
+
 	updatedID, err := s.repository.UpdateUserDrink(ctx, dto)
 
 	if err != nil {
@@ -2,23 +2,49 @@ package event
 
 import "time"
 
+// DateTime и EndTime задаются по часам места проведения ивента в поясе Timezone.
+// DrinksPerGuest - сколько напитков каждой категории выпьет гость, заменяет значения по умолчанию
 type CreateEventDTO struct {
-	UserID             string    `json:"user_id"`
-	Name               string    `json:"name"`
-	Description        string    `json:"description"`
-	ParticipantsNumber uint32    `json:"participants_number"`
-	DateTime           time.Time `json:"date_time"`
-	MenuID             string    `json:"menu_id"`
+	UserID             string             `json:"user_id"`
+	Name               string             `json:"name"`
+	Description        string             `json:"description"`
+	ParticipantsNumber uint32             `json:"participants_number"`
+	DateTime           time.Time          `json:"date_time"`
+	EndTime            *time.Time         `json:"end_time,omitempty"`
+	Timezone           string             `json:"timezone"`
+	DrinksPerGuest     map[string]float64 `json:"drinks_per_guest,omitempty"`
+	MenuID             string             `json:"menu_id"`
 }
 
 type CompleteEventDTO struct {
 	ID string `json:"id"`
 }
 
+// UserID берется из токена, отменить ивент может только его организатор
+type CancelEventDTO struct {
+	ID     string `json:"id"`
+	UserID string `json:"-"`
+}
+
 type FindAllEventsDTO struct {
 	UserID string `json:"user_id"`
 }
 
+// UserID берется из токена, просмотреть изменения может только организатор ивента
+type ReviewShoppingDTO struct {
+	ID     string `json:"id"`
+	UserID string `json:"-"`
+}
+
+type FindReportDTO struct {
+	EventID string `json:"event_id"`
+	UserID  string `json:"user_id"`
+}
+
+type FindArchiveDTO struct {
+	UserID string `json:"user_id"`
+}
+
 type RespAllEvents struct {
 	Events []Event `json:"events"`
 }
@@ -28,12 +54,17 @@ type FindEventDTO struct {
 	UserID string `json:"user_id"`
 }
 
+// DateTime и EndTime задаются по часам места проведения ивента в поясе Timezone.
+// Пустой Timezone - пояс ивента не меняется
 type UpdateEventDTO struct {
-	ID                 string    `json:"id"`
-	Name               string    `json:"name"`
-	Description        string    `json:"description"`
-	ParticipantsNumber uint32    `json:"participants_number"`
-	DateTime           time.Time `json:"date_time"`
+	ID                 string             `json:"id"`
+	Name               string             `json:"name"`
+	Description        string             `json:"description"`
+	ParticipantsNumber uint32             `json:"participants_number"`
+	DateTime           time.Time          `json:"date_time"`
+	EndTime            *time.Time         `json:"end_time,omitempty"`
+	Timezone           string             `json:"timezone"`
+	DrinksPerGuest     map[string]float64 `json:"drinks_per_guest,omitempty"`
 }
 
 type RespCreateEvent struct {
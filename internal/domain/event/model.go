@@ -1,19 +1,137 @@
 package event
 
 import (
+	"fmt"
+	"restapi/internal/domain/menu"
 	"restapi/internal/domain/report"
 	"time"
 )
 
+// Часовой пояс ивентов, созданных без него
+const DefaultTimezone = "Europe/Moscow"
+
+// DateTime и EndTime хранятся в UTC, а в ответах переводятся в часовой пояс Timezone (имя IANA).
+// ReviewedShoppingList - список покупок, который хост видел последним, от него считается ShoppingDiff
 type Event struct {
-	ID                 string        `json:"id"`
-	UserID             string        `json:"user_id"`
-	Name               string        `json:"name"`
-	Description        string        `json:"description"`
-	ParticipantsNumber uint32        `json:"participants_number"`
-	DateTime           time.Time     `json:"date_time"`
-	Status             string        `json:"status"`
-	MenuID             string        `json:"menu_id"`
-	ShoppingList       []string      `json:"shopping_list"`
-	Report             report.Report `json:"report"`
+	ID                   string             `json:"id"`
+	UserID               string             `json:"user_id"`
+	Name                 string             `json:"name"`
+	Description          string             `json:"description"`
+	ParticipantsNumber   uint32             `json:"participants_number"`
+	DateTime             time.Time          `json:"date_time"`
+	EndTime              *time.Time         `json:"end_time,omitempty"`
+	Timezone             string             `json:"timezone"`
+	Status               string             `json:"status"`
+	MenuID               string             `json:"menu_id"`
+	DrinksPerGuest       map[string]float64 `json:"drinks_per_guest,omitempty"`
+	ShoppingList         []ShoppingItem     `json:"shopping_list"`
+	ShoppingDiff         *ShoppingDiff      `json:"shopping_diff,omitempty"`
+	ReviewedShoppingList []ShoppingItem     `json:"-"`
+	Report               *report.Report     `json:"report,omitempty"`
+	ClosedAt             *time.Time         `json:"closed_at,omitempty"`
+	Snapshot             *Snapshot          `json:"snapshot,omitempty"`
+}
+
+// Позиция списка покупок: сколько ингредиента нужно на ивент.
+// Amount указан в базовой единице units: ml, g или pcs. У льда Name содержит ice_type
+type ShoppingItem struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Unit   string `json:"unit,omitempty"`
+	Amount uint32 `json:"amount"`
+}
+
+// Изменения списка покупок с последнего просмотра хостом
+type ShoppingDiff struct {
+	Added     []ShoppingItem   `json:"added"`
+	Removed   []ShoppingItem   `json:"removed"`
+	Changed   []ShoppingChange `json:"changed"`
+	ChangedAt time.Time        `json:"changed_at"`
+}
+
+// Позиция, у которой изменилось количество: было From, стало To
+type ShoppingChange struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Unit string `json:"unit,omitempty"`
+	From uint32 `json:"from"`
+	To   uint32 `json:"to"`
+}
+
+func (d ShoppingDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Снимок меню и ингредиентов ивента на момент его завершения.
+// Меню и ингредиенты могут меняться после ивента, а архив остается прежним
+type Snapshot struct {
+	Menu        menu.Menu            `json:"menu"`
+	Ingredients []SnapshotIngredient `json:"ingredients"`
+}
+
+type SnapshotIngredient struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Unit   string `json:"unit,omitempty"`
+	Volume uint32 `json:"volume"`
+	Cost   uint32 `json:"cost"`
+}
+
+// Localize переводит время ивента в его часовой пояс
+func (e *Event) Localize() error {
+	loc, err := LoadLocation(e.Timezone)
+	if err != nil {
+		return err
+	}
+
+	e.DateTime = e.DateTime.In(loc)
+	if e.EndTime != nil {
+		endTime := e.EndTime.In(loc)
+		e.EndTime = &endTime
+	}
+
+	if e.ClosedAt != nil {
+		closedAt := e.ClosedAt.In(loc)
+		e.ClosedAt = &closedAt
+	}
+
+	return nil
+}
+
+// LoadLocation находит часовой пояс по имени IANA, пустое имя - DefaultTimezone
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		name = DefaultTimezone
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone: %s", name)
+	}
+
+	return loc, nil
+}
+
+// LocalToUTC читает показания часов t как время в поясе loc и переводит в UTC.
+// t передается без смещения, со смещением Z или со смещением пояса loc в этот момент,
+// как его возвращают ответы API. Другое смещение противоречило бы поясу loc.
+// Время, которого нет в поясе из-за перехода на летнее время, не принимается
+func LocalToUTC(t time.Time, loc *time.Location) (time.Time, error) {
+	if _, offset := t.Zone(); offset != 0 {
+		if _, locOffset := t.In(loc).Zone(); locOffset != offset {
+			return time.Time{}, fmt.Errorf("utc offset of time %s does not match timezone %s",
+				t.Format(time.RFC3339), loc.String())
+		}
+
+		return t.UTC(), nil
+	}
+
+	local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+
+	if local.Hour() != t.Hour() || local.Minute() != t.Minute() || local.Day() != t.Day() {
+		return time.Time{}, fmt.Errorf("time %s does not exist in timezone %s",
+			t.Format("2006-01-02 15:04"), loc.String())
+	}
+
+	return local.UTC(), nil
 }
@@ -0,0 +1,127 @@
+package event
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocalToUTC(t *testing.T) {
+	berlin, err := LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moscow, err := LoadLocation("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		local   time.Time
+		loc     *time.Location
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:  "winter time",
+			local: time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC),
+			loc:   berlin,
+			want:  time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC),
+		},
+		{
+			name:    "skipped by dst transition",
+			local:   time.Date(2024, 3, 31, 2, 30, 0, 0, time.UTC),
+			loc:     berlin,
+			wantErr: true,
+		},
+		{
+			name:  "summer time",
+			local: time.Date(2024, 3, 31, 3, 30, 0, 0, time.UTC),
+			loc:   berlin,
+			want:  time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "after dst end",
+			local: time.Date(2024, 10, 27, 3, 30, 0, 0, time.UTC),
+			loc:   berlin,
+			want:  time.Date(2024, 10, 27, 2, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "default timezone",
+			local: time.Date(2024, 7, 1, 20, 0, 0, 0, time.UTC),
+			loc:   moscow,
+			want:  time.Date(2024, 7, 1, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "time without location",
+			local: time.Date(2024, 7, 1, 20, 0, 0, 0, time.FixedZone("", 0)),
+			loc:   berlin,
+			want:  time.Date(2024, 7, 1, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "offset of the timezone",
+			local: time.Date(2024, 7, 1, 20, 0, 0, 0, time.FixedZone("", 2*60*60)),
+			loc:   berlin,
+			want:  time.Date(2024, 7, 1, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "offset picks one of the repeated hours",
+			local: time.Date(2024, 10, 27, 2, 30, 0, 0, time.FixedZone("", 60*60)),
+			loc:   berlin,
+			want:  time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC),
+		},
+		{
+			name:    "offset of another timezone",
+			local:   time.Date(2024, 7, 1, 20, 0, 0, 0, time.FixedZone("", 3*60*60)),
+			loc:     berlin,
+			wantErr: true,
+		},
+		{
+			name:    "winter offset in summer",
+			local:   time.Date(2024, 7, 1, 20, 0, 0, 0, time.FixedZone("", 60*60)),
+			loc:     berlin,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LocalToUTC(tt.local, tt.loc)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LocalToUTC() = %s, want error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LocalToUTC() error: %v", err)
+			}
+
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("LocalToUTC() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Время в час перевода часов назад бывает дважды, подходит любое из двух
+func TestLocalToUTCRepeatedHour(t *testing.T) {
+	berlin, err := LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LocalToUTC(time.Date(2024, 10, 27, 2, 30, 0, 0, time.UTC), berlin)
+	if err != nil {
+		t.Fatalf("LocalToUTC() error: %v", err)
+	}
+
+	summer := time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC)
+	winter := time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC)
+
+	if !got.Equal(summer) && !got.Equal(winter) {
+		t.Errorf("LocalToUTC() = %s, want %s or %s", got, summer, winter)
+	}
+}
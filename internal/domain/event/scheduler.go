@@ -0,0 +1,140 @@
+package event
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"restapi/pkg/logging"
+)
+
+const (
+	// scheduled jobs
+	jobActivate = "activate"
+	jobComplete = "complete"
+
+	// Задание, которое не удалось выполнить, повторяется через minRetryDelay,
+	// каждый следующий раз ждать в два раза дольше, но не дольше maxRetryDelay
+	minRetryDelay = 10 * time.Second
+	maxRetryDelay = 5 * time.Minute
+
+	// Сколько ждать выполнения одного задания
+	jobTimeout = 30 * time.Second
+)
+
+type scheduledJob struct {
+	timer *time.Timer
+	gen   uint64
+}
+
+// scheduler запускает задания ивентов в заданное время и повторяет их при ошибке.
+// Таймеры живут в памяти, а время заданий хранится в таблице ивентов,
+// поэтому после перезапуска приложения задания восстанавливаются из нее.
+// gen отличает текущий таймер задания от замененного, который мог уже сработать
+type scheduler struct {
+	mu   sync.Mutex
+	jobs map[string]*scheduledJob
+	gen  uint64
+
+	run    func(ctx context.Context, job, eventID string) error
+	logger *logging.Logger
+}
+
+func newScheduler(run func(ctx context.Context, job, eventID string) error, logger *logging.Logger) *scheduler {
+	return &scheduler{
+		jobs:   make(map[string]*scheduledJob),
+		run:    run,
+		logger: logger,
+	}
+}
+
+// schedule ставит задание на время at, заменяя уже запланированное.
+// Задание с прошедшим временем выполняется сразу
+func (sc *scheduler) schedule(job, eventID string, at time.Time) {
+	key := jobKey(job, eventID)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if old, ok := sc.jobs[key]; ok {
+		old.timer.Stop()
+	}
+
+	sc.gen++
+	gen := sc.gen
+
+	sc.jobs[key] = &scheduledJob{
+		gen: gen,
+		timer: time.AfterFunc(time.Until(at), func() {
+			sc.fire(job, eventID, gen, minRetryDelay)
+		}),
+	}
+
+	sc.logger.Infof("event %s job %s is scheduled at %s", eventID, job, at.UTC().Format(time.RFC3339))
+}
+
+func (sc *scheduler) cancel(job, eventID string) {
+	key := jobKey(job, eventID)
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if old, ok := sc.jobs[key]; ok {
+		old.timer.Stop()
+		delete(sc.jobs, key)
+	}
+}
+
+func (sc *scheduler) fire(job, eventID string, gen uint64, retryDelay time.Duration) {
+	key := jobKey(job, eventID)
+
+	if !sc.isCurrent(key, gen) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	err := sc.run(ctx, job, eventID)
+	cancel()
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	current, ok := sc.jobs[key]
+	if !ok || current.gen != gen {
+		return
+	}
+
+	if err == nil {
+		delete(sc.jobs, key)
+		return
+	}
+
+	sc.logger.Errorf("event %s job %s error, retry in %s: %v", eventID, job, retryDelay, err)
+
+	nextDelay := nextRetryDelay(retryDelay)
+
+	current.timer = time.AfterFunc(retryDelay, func() {
+		sc.fire(job, eventID, gen, nextDelay)
+	})
+}
+
+// nextRetryDelay возвращает задержку следующего повтора задания
+func nextRetryDelay(retryDelay time.Duration) time.Duration {
+	nextDelay := retryDelay * 2
+	if nextDelay > maxRetryDelay {
+		nextDelay = maxRetryDelay
+	}
+	return nextDelay
+}
+
+func (sc *scheduler) isCurrent(key string, gen uint64) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	current, ok := sc.jobs[key]
+	return ok && current.gen == gen
+}
+
+func jobKey(job, eventID string) string {
+	return job + ":" + eventID
+}
@@ -0,0 +1,218 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"restapi/internal/domain/menu"
+	"restapi/internal/domain/report"
+	"restapi/pkg/logging"
+
+	"github.com/sirupsen/logrus"
+)
+
+func testLogger() *logging.Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	return &logging.Logger{Entry: logrus.NewEntry(l)}
+}
+
+// testRun - задание, которое завершается ошибкой первые fails раз
+type testRun struct {
+	mu    sync.Mutex
+	fails int
+	calls int
+	done  chan struct{}
+}
+
+func newTestRun(fails int) *testRun {
+	return &testRun{fails: fails, done: make(chan struct{}, 16)}
+}
+
+func (r *testRun) run(ctx context.Context, job, eventID string) error {
+	r.mu.Lock()
+	defer func() {
+		r.mu.Unlock()
+		r.done <- struct{}{}
+	}()
+
+	r.calls++
+	if r.calls <= r.fails {
+		return errors.New("job failed")
+	}
+	return nil
+}
+
+func (r *testRun) wait(t *testing.T, calls int) {
+	t.Helper()
+
+	for i := 0; i < calls; i++ {
+		select {
+		case <-r.done:
+		case <-time.After(time.Second):
+			t.Fatalf("job is called %d times, want %d", i, calls)
+		}
+	}
+}
+
+func (r *testRun) callsCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+// addJob ставит задание в таблицу планировщика без таймера, как будто его таймер уже сработал
+func addJob(sc *scheduler, job, eventID string) uint64 {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.gen++
+	sc.jobs[jobKey(job, eventID)] = &scheduledJob{gen: sc.gen, timer: time.NewTimer(time.Hour)}
+	return sc.gen
+}
+
+func hasJob(sc *scheduler, job, eventID string) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	_, ok := sc.jobs[jobKey(job, eventID)]
+	return ok
+}
+
+func TestSchedulerFire(t *testing.T) {
+	tests := []struct {
+		name  string
+		fails int
+		calls int
+	}{
+		{name: "success", fails: 0, calls: 1},
+		{name: "retry after error", fails: 1, calls: 2},
+		{name: "retry until success", fails: 3, calls: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRun(tt.fails)
+			sc := newScheduler(r.run, testLogger())
+
+			gen := addJob(sc, jobActivate, "event")
+			sc.fire(jobActivate, "event", gen, time.Millisecond)
+
+			r.wait(t, tt.calls)
+
+			// Задание удаляется после успешного выполнения, которое завершает wait
+			deadline := time.Now().Add(time.Second)
+			for hasJob(sc, jobActivate, "event") {
+				if time.Now().After(deadline) {
+					t.Fatal("job is not removed after success")
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			if calls := r.callsCount(); calls != tt.calls {
+				t.Errorf("job is called %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestSchedulerFireStale(t *testing.T) {
+	r := newTestRun(0)
+	sc := newScheduler(r.run, testLogger())
+
+	gen := addJob(sc, jobComplete, "event")
+
+	// Задание перепланировано, старый таймер не должен его выполнить
+	addJob(sc, jobComplete, "event")
+	sc.fire(jobComplete, "event", gen, time.Millisecond)
+
+	if calls := r.callsCount(); calls != 0 {
+		t.Errorf("stale job is called %d times", calls)
+	}
+
+	if !hasJob(sc, jobComplete, "event") {
+		t.Error("current job is removed by stale timer")
+	}
+}
+
+func TestSchedulerFireCancelled(t *testing.T) {
+	r := newTestRun(1)
+
+	var sc *scheduler
+	sc = newScheduler(func(ctx context.Context, job, eventID string) error {
+		// Задание отменено, пока выполнялось, - ошибка не повторяется
+		sc.cancel(job, eventID)
+		return r.run(ctx, job, eventID)
+	}, testLogger())
+
+	gen := addJob(sc, jobActivate, "event")
+	sc.fire(jobActivate, "event", gen, time.Millisecond)
+
+	r.wait(t, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	if calls := r.callsCount(); calls != 1 {
+		t.Errorf("cancelled job is called %d times, want 1", calls)
+	}
+
+	if hasJob(sc, jobActivate, "event") {
+		t.Error("cancelled job is scheduled again")
+	}
+}
+
+func TestNextRetryDelay(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  time.Duration
+	}{
+		{delay: minRetryDelay, want: 2 * minRetryDelay},
+		{delay: time.Minute, want: 2 * time.Minute},
+		{delay: 3 * time.Minute, want: maxRetryDelay},
+		{delay: maxRetryDelay, want: maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		if got := nextRetryDelay(tt.delay); got != tt.want {
+			t.Errorf("nextRetryDelay(%s) = %s, want %s", tt.delay, got, tt.want)
+		}
+	}
+}
+
+// Хранилище, в котором задание активации или завершения устарело
+type staleJobRepository struct {
+	Repository
+	endTime   time.Time
+	completed bool
+}
+
+func (r *staleJobRepository) SetActive(context.Context, string, time.Time) (bool, error) {
+	return false, nil
+}
+
+func (r *staleJobRepository) FindEvent(_ context.Context, id string) (Event, error) {
+	return Event{ID: id, Status: statusActive, EndTime: &r.endTime}, nil
+}
+
+func (r *staleJobRepository) CompleteEvent(context.Context, string, menu.Menu, report.Report, *time.Time) (bool, error) {
+	r.completed = true
+	return true, nil
+}
+
+func TestRunJobStale(t *testing.T) {
+	repo := &staleJobRepository{endTime: time.Now().Add(time.Hour)}
+	s := &service{repository: repo, logger: testLogger()}
+
+	for _, job := range []string{jobActivate, jobComplete} {
+		if err := s.runJob(context.Background(), job, "1"); err != nil {
+			t.Errorf("runJob(%s) error = %v, want the stale job to be skipped", job, err)
+		}
+	}
+
+	if repo.completed {
+		t.Error("event is completed before its end time")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"restapi/internal/domain/menu"
+	"restapi/internal/domain/order"
+	"restapi/internal/domain/report"
 	"restapi/pkg/logging"
 	"time"
 )
@@ -12,39 +14,64 @@ const (
 	statusCreated   = "Created"
 	statusActive    = "Active"
 	statusCompleted = "Completed"
+	statusCancelled = "Cancelled"
 )
 
 type Service interface {
 	NewEvent(context.Context, CreateEventDTO) (Event, error)
-	SetActive(timer *time.Timer, id string)
+	StartScheduler(context.Context) error
 	CompleteEvent(context.Context, CompleteEventDTO) error
+	CancelEvent(context.Context, CancelEventDTO) error
 	FindAllUserEvents(context.Context, FindAllEventsDTO) (RespAllEvents, error)
+	FindArchivedEvents(context.Context, FindArchiveDTO) (RespAllEvents, error)
+	FindReport(context.Context, FindReportDTO) (report.Report, error)
+	EachOrder(ctx context.Context, eventID string, fn func(order.Order) error) error
+	MenuChanged(ctx context.Context, menuID string) error
+	ReviewShoppingList(context.Context, ReviewShoppingDTO) error
 	FindEvent(context.Context, FindEventDTO) (Event, error)
+	FindEventMenu(context.Context, Event) (menu.Menu, error)
 	UpdateEvent(context.Context, UpdateEventDTO) error
 }
 
 type service struct {
 	repository Repository
 	menuRepos  menu.Repository
+	orderRepos order.Repository
+	scheduler  *scheduler
 	logger     *logging.Logger
+
+	// Сколько напитков каждой категории выпьет гость, если в ивенте не задано иное
+	drinksPerGuest map[string]float64
 }
 
-func NewService(repository Repository, menuRepos menu.Repository, logger *logging.Logger) Service {
-	return &service{
-		repository: repository,
-		menuRepos:  menuRepos,
-		logger:     logger,
+func NewService(repository Repository, menuRepos menu.Repository, orderRepos order.Repository,
+	drinksPerGuest map[string]float64, logger *logging.Logger) Service {
+	s := &service{
+		repository:     repository,
+		menuRepos:      menuRepos,
+		orderRepos:     orderRepos,
+		drinksPerGuest: drinksPerGuest,
+		logger:         logger,
 	}
+	s.scheduler = newScheduler(s.runJob, logger)
+
+	return s
 }
 
 func (s *service) NewEvent(ctx context.Context, dto CreateEventDTO) (Event, error) {
 	s.logger.Infof("creating event %s", dto.Name)
 
-	// Так как time.Now() дает время с часовым поясом, а в DateTime без, то вычитаем 3 часа
-	timeUntil := (time.Until(dto.DateTime) - (3 * time.Hour))
-	timer := time.NewTimer(timeUntil)
+	var err error
+	dto.Timezone, dto.DateTime, dto.EndTime, err = eventTimes(dto.Timezone, dto.DateTime, dto.EndTime)
+	if err != nil {
+		return Event{}, err
+	}
+
+	if err := validateDrinksPerGuest(dto.DrinksPerGuest); err != nil {
+		return Event{}, err
+	}
 
-	// Получить напитки из меню и найти наименования всех ингредиентов
+	// Получить напитки из меню и посчитать ингредиенты на всех гостей
 	menuDTO := menu.FindMenuDTO{
 		ID: dto.MenuID,
 	}
@@ -54,7 +81,7 @@ func (s *service) NewEvent(ctx context.Context, dto CreateEventDTO) (Event, erro
 		return Event{}, fmt.Errorf("finding menu error: %v", err)
 	}
 
-	shopList := s.GetShoppingList(menu)
+	shopList := ShoppingList(menu, dto.ParticipantsNumber, drinksPerGuest(s.drinksPerGuest, dto.DrinksPerGuest))
 
 	eventID, err := s.repository.CreateEvent(ctx, dto, shopList)
 
@@ -69,44 +96,184 @@ func (s *service) NewEvent(ctx context.Context, dto CreateEventDTO) (Event, erro
 		Description:        dto.Description,
 		ParticipantsNumber: dto.ParticipantsNumber,
 		DateTime:           dto.DateTime,
+		EndTime:            dto.EndTime,
+		Timezone:           dto.Timezone,
 		Status:             statusCreated,
 		MenuID:             dto.MenuID,
+		DrinksPerGuest:     dto.DrinksPerGuest,
 		ShoppingList:       shopList,
 	}
 
-	go s.SetActive(timer, eventID)
+	s.schedule(evnt)
 
 	s.logger.Infof("event is created, event_id: %s", eventID)
 
+	if err := evnt.Localize(); err != nil {
+		return Event{}, err
+	}
+
 	return evnt, nil
 }
 
-// TODO обработка ошибки
-func (s *service) SetActive(timer *time.Timer, id string) {
-	<-timer.C
+// StartScheduler планирует активацию и завершение ивентов, сохраненных до запуска приложения.
+// Задания, время которых уже прошло, выполняются сразу
+func (s *service) StartScheduler(ctx context.Context) error {
+	s.logger.Info("loading scheduled events")
 
-	err := s.repository.SetActive(context.TODO(), id)
+	events, err := s.repository.FindScheduledEvents(ctx)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("finding scheduled events error: %v", err)
 	}
 
-	s.logger.Infof("event %s now is Active", id)
+	for _, evnt := range events {
+		s.schedule(evnt)
+	}
+
+	s.logger.Infof("%d events are scheduled", len(events))
+
+	return nil
 }
 
+// schedule ставит задания ивента по его статусу и времени: созданный ивент ждет активации,
+// а незавершенный ивент с временем окончания - завершения
+func (s *service) schedule(evnt Event) {
+	if evnt.Status == statusCreated {
+		s.scheduler.schedule(jobActivate, evnt.ID, evnt.DateTime)
+	} else {
+		s.scheduler.cancel(jobActivate, evnt.ID)
+	}
+
+	if evnt.EndTime != nil && (evnt.Status == statusCreated || evnt.Status == statusActive) {
+		s.scheduler.schedule(jobComplete, evnt.ID, *evnt.EndTime)
+	} else {
+		s.scheduler.cancel(jobComplete, evnt.ID)
+	}
+}
+
+func (s *service) runJob(ctx context.Context, job, eventID string) error {
+	// Задание могло устареть: ивент изменили или закрыли на другом экземпляре.
+	// Тогда хранилище ничего не меняет, и задание пропускается
+	now := time.Now().UTC()
+
+	switch job {
+	case jobActivate:
+		activated, err := s.repository.SetActive(ctx, eventID, now)
+		if err != nil {
+			return err
+		}
+
+		if !activated {
+			s.logger.Infof("activation of event %s is skipped: the event is not Created or starts later", eventID)
+			return nil
+		}
+
+		s.logger.Infof("event %s now is Active", eventID)
+	case jobComplete:
+		completed, err := s.complete(ctx, eventID, &now)
+		if err != nil {
+			return err
+		}
+
+		if !completed {
+			s.logger.Infof("completion of event %s is skipped: the event is already closed or ends later", eventID)
+			return nil
+		}
+
+		s.scheduler.cancel(jobActivate, eventID)
+
+		s.logger.Infof("event %s is Completed by end time", eventID)
+	default:
+		return fmt.Errorf("unknown event job: %s", job)
+	}
+
+	return nil
+}
+
+// CompleteEvent завершает ивент, сохраняет снимок меню и ингредиентов и отчет по заказам.
+// Завершенный ивент остается в архиве пользователя
 func (s *service) CompleteEvent(ctx context.Context, dto CompleteEventDTO) error {
 	s.logger.Infof("completing event %s", dto.ID)
 
-	err := s.repository.DeleteEvent(ctx, dto)
-
+	completed, err := s.complete(ctx, dto.ID, nil)
 	if err != nil {
 		return err
 	}
 
+	if !completed {
+		return fmt.Errorf("event %s not found or is already completed or cancelled", dto.ID)
+	}
+
+	s.scheduler.cancel(jobActivate, dto.ID)
+	s.scheduler.cancel(jobComplete, dto.ID)
+
 	s.logger.Infof("event is Completed, event_id: %s", dto.ID)
 
 	return nil
 }
 
+// complete делает снимок меню ивента, собирает отчет и завершает ивент.
+// Если задан endedBy, завершается только ивент, время окончания которого не позже endedBy.
+// false - ивент уже завершен или отменен или еще не закончился
+func (s *service) complete(ctx context.Context, eventID string, endedBy *time.Time) (bool, error) {
+	evnt, err := s.repository.FindEvent(ctx, eventID)
+	if err != nil {
+		return false, fmt.Errorf("finding event error: %v", err)
+	}
+
+	if evnt.Status != statusCreated && evnt.Status != statusActive {
+		return false, nil
+	}
+
+	if endedBy != nil && (evnt.EndTime == nil || evnt.EndTime.After(*endedBy)) {
+		return false, nil
+	}
+
+	mn, err := s.menuRepos.FindMenu(ctx, menu.FindMenuDTO{ID: evnt.MenuID})
+	if err != nil {
+		return false, fmt.Errorf("finding menu error: %v", err)
+	}
+
+	orders, err := s.orderRepos.FindEventOrders(ctx, order.FindEventOrdersDTO{EventID: eventID})
+	if err != nil {
+		return false, fmt.Errorf("finding event orders error: %v", err)
+	}
+
+	purchases, err := s.repository.FindPurchases(ctx, eventID)
+	if err != nil {
+		return false, fmt.Errorf("finding event ingredients error: %v", err)
+	}
+
+	loc, err := LoadLocation(evnt.Timezone)
+	if err != nil {
+		return false, err
+	}
+
+	rep := report.Generate(eventID, orders, mn, purchases, loc, time.Now().UTC())
+
+	return s.repository.CompleteEvent(ctx, eventID, mn, rep, endedBy)
+}
+
+// CancelEvent отменяет ивент, который еще не завершен. Отмененный ивент тоже остается в архиве
+func (s *service) CancelEvent(ctx context.Context, dto CancelEventDTO) error {
+	s.logger.Infof("cancelling event %s", dto.ID)
+
+	cancelled, err := s.repository.CancelEvent(ctx, dto)
+	if err != nil {
+		return err
+	}
+
+	if !cancelled {
+		return fmt.Errorf("event %s of user %s not found or is already completed or cancelled", dto.ID, dto.UserID)
+	}
+
+	s.scheduler.cancel(jobActivate, dto.ID)
+	s.scheduler.cancel(jobComplete, dto.ID)
+
+	s.logger.Infof("event is Cancelled, event_id: %s", dto.ID)
+
+	return nil
+}
+
 func (s *service) FindAllUserEvents(ctx context.Context, dto FindAllEventsDTO) (RespAllEvents, error) {
 	s.logger.Infof("find all user events, user_id: %s", dto.UserID)
 
@@ -116,11 +283,62 @@ func (s *service) FindAllUserEvents(ctx context.Context, dto FindAllEventsDTO) (
 		return RespAllEvents{}, err
 	}
 
+	for i := range events.Events {
+		if err := events.Events[i].Localize(); err != nil {
+			return RespAllEvents{}, err
+		}
+	}
+
 	s.logger.Infof("all user events is found")
 
 	return events, nil
 }
 
+func (s *service) FindArchivedEvents(ctx context.Context, dto FindArchiveDTO) (RespAllEvents, error) {
+	s.logger.Infof("find archived user events, user_id: %s", dto.UserID)
+
+	events, err := s.repository.FindArchivedEvents(ctx, dto)
+
+	if err != nil {
+		return RespAllEvents{}, err
+	}
+
+	for i := range events.Events {
+		if err := events.Events[i].Localize(); err != nil {
+			return RespAllEvents{}, err
+		}
+	}
+
+	s.logger.Infof("archived user events are found")
+
+	return events, nil
+}
+
+// FindReport возвращает отчет завершенного ивента
+func (s *service) FindReport(ctx context.Context, dto FindReportDTO) (report.Report, error) {
+	s.logger.Infof("find event report, event_id: %s", dto.EventID)
+
+	evnt, err := s.repository.FindUserEvent(ctx, FindEventDTO{ID: dto.EventID, UserID: dto.UserID})
+	if err != nil {
+		return report.Report{}, err
+	}
+
+	if evnt.Report == nil {
+		return report.Report{}, fmt.Errorf("event %s has no report, its status is %s", dto.EventID, evnt.Status)
+	}
+
+	s.logger.Infof("event report is found")
+
+	return *evnt.Report, nil
+}
+
+// EachOrder по очереди передает заказы ивента в fn, например, для выгрузки в файл
+func (s *service) EachOrder(ctx context.Context, eventID string, fn func(order.Order) error) error {
+	s.logger.Infof("read event orders, event_id: %s", eventID)
+
+	return s.orderRepos.EachEventOrder(ctx, order.FindEventOrdersDTO{EventID: eventID}, fn)
+}
+
 func (s *service) FindEvent(ctx context.Context, dto FindEventDTO) (Event, error) {
 	s.logger.Infof("find one user event, user_id: %s, event_id: %s", dto.UserID, dto.ID)
 
@@ -130,18 +348,69 @@ func (s *service) FindEvent(ctx context.Context, dto FindEventDTO) (Event, error
 		return Event{}, err
 	}
 
+	if err := evnt.Localize(); err != nil {
+		return Event{}, err
+	}
+
 	s.logger.Infof("user event is found")
 	s.logger.Tracef("event name: %s", evnt.Name)
 
 	return evnt, nil
 }
 
+// FindEventMenu возвращает меню ивента: у завершенного - снимок на момент завершения,
+// у остальных - текущее меню
+func (s *service) FindEventMenu(ctx context.Context, evnt Event) (menu.Menu, error) {
+	if evnt.Snapshot != nil {
+		return evnt.Snapshot.Menu, nil
+	}
+
+	mn, err := s.menuRepos.FindMenu(ctx, menu.FindMenuDTO{ID: evnt.MenuID})
+	if err != nil {
+		return menu.Menu{}, fmt.Errorf("finding menu error: %v", err)
+	}
+
+	return mn, nil
+}
+
 func (s *service) UpdateEvent(ctx context.Context, dto UpdateEventDTO) error {
 	s.logger.Infof("update event")
 
-	err := s.repository.UpdateEvent(ctx, dto)
+	// Без пояса время читается в поясе, сохраненном у ивента
+	if dto.Timezone == "" {
+		current, err := s.repository.FindEvent(ctx, dto.ID)
+		if err != nil {
+			return fmt.Errorf("finding event error: %v", err)
+		}
 
+		dto.Timezone = current.Timezone
+	}
+
+	var err error
+	dto.Timezone, dto.DateTime, dto.EndTime, err = eventTimes(dto.Timezone, dto.DateTime, dto.EndTime)
+	if err != nil {
+		return err
+	}
+
+	if err := validateDrinksPerGuest(dto.DrinksPerGuest); err != nil {
+		return err
+	}
+
+	evnt, err := s.repository.UpdateEvent(ctx, dto)
+
+	if err != nil {
+		return err
+	}
+
+	s.schedule(evnt)
+
+	// Завершенный ивент не изменяется, поэтому список покупок пересчитывается всегда
+	mn, err := s.menuRepos.FindMenu(ctx, menu.FindMenuDTO{ID: evnt.MenuID})
 	if err != nil {
+		return fmt.Errorf("finding menu error: %v", err)
+	}
+
+	if err := s.refreshShoppingList(ctx, evnt, mn); err != nil {
 		return err
 	}
 
@@ -150,43 +419,113 @@ func (s *service) UpdateEvent(ctx context.Context, dto UpdateEventDTO) error {
 	return nil
 }
 
-// Может быть как то оптимизировать?
-func (s *service) GetShoppingList(menu menu.Menu) []string {
-	Hash := make(map[string]bool, 0)
-
-	for _, drinks := range menu.Drinks {
-		for _, drink := range drinks {
-			for _, liq := range drink.Composition.Liquids {
-				Hash[liq.Name] = true
-			}
-
-			for _, solB := range drink.Composition.SolidsBulk {
-				Hash[solB.Name] = true
-			}
-
-			for _, solU := range drink.Composition.SolidsUnit {
-				Hash[solU.Name] = true
-			}
-
-			switch drink.OrderIceType {
-			case "block_ice":
-				Hash["Лед блоками (block)"] = true
-			case "cubed_ice":
-				Hash["Лед кубиками (cubed)"] = true
-			case "cracked_ice":
-				Hash["Ломаный лед (cracked)"] = true
-			case "nugget_ice":
-				Hash["Пальчиковый лед (nugget)"] = true
-			case "crushed_ice":
-				Hash["Дробленый лед (crushed)"] = true
-			}
+// MenuChanged пересчитывает списки покупок незавершенных ивентов с этим меню
+func (s *service) MenuChanged(ctx context.Context, menuID string) error {
+	events, err := s.repository.FindMenuEvents(ctx, menuID)
+	if err != nil {
+		return fmt.Errorf("finding menu events error: %v", err)
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	s.logger.Infof("menu %s is changed, refresh shopping lists of %d events", menuID, len(events))
+
+	mn, err := s.menuRepos.FindMenu(ctx, menu.FindMenuDTO{ID: menuID})
+	if err != nil {
+		return fmt.Errorf("finding menu error: %v", err)
+	}
+
+	// Ошибка одного ивента не мешает обновить списки остальных
+	var failed int
+	for _, evnt := range events {
+		if err := s.refreshShoppingList(ctx, evnt, mn); err != nil {
+			s.logger.Errorf("event %s shopping list refresh error: %v", evnt.ID, err)
+			failed++
 		}
 	}
 
-	list := make([]string, 0, len(Hash))
-	for k := range Hash {
-		list = append(list, k)
+	if failed > 0 {
+		return fmt.Errorf("shopping lists of %d of %d events are not refreshed", failed, len(events))
+	}
+
+	return nil
+}
+
+// refreshShoppingList пересчитывает список покупок ивента и изменения с последнего просмотра хостом.
+// Если список не изменился, ничего не сохраняется. Если хост успел просмотреть изменения
+// или ивент закрыли после чтения evnt, пересчет пропускается
+func (s *service) refreshShoppingList(ctx context.Context, evnt Event, mn menu.Menu) error {
+	shopList := ShoppingList(mn, evnt.ParticipantsNumber, drinksPerGuest(s.drinksPerGuest, evnt.DrinksPerGuest))
+
+	if DiffShoppingLists(evnt.ShoppingList, shopList, time.Time{}).IsEmpty() {
+		return nil
+	}
+
+	var diff *ShoppingDiff
+	if d := DiffShoppingLists(evnt.ReviewedShoppingList, shopList, time.Now().UTC()); !d.IsEmpty() {
+		diff = &d
+	}
+
+	updated, err := s.repository.UpdateShoppingList(ctx, evnt.ID, shopList, diff, evnt.ReviewedShoppingList)
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		s.logger.Infof("event %s shopping list refresh is skipped: the event is changed or closed", evnt.ID)
+		return nil
+	}
+
+	s.logger.Infof("event %s shopping list is updated", evnt.ID)
+
+	return nil
+}
+
+// ReviewShoppingList отмечает изменения списка покупок просмотренными
+func (s *service) ReviewShoppingList(ctx context.Context, dto ReviewShoppingDTO) error {
+	s.logger.Infof("review event %s shopping list", dto.ID)
+
+	err := s.repository.ReviewShoppingList(ctx, dto)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Infof("event %s shopping list is reviewed", dto.ID)
+
+	return nil
+}
+
+// eventTimes переводит время начала и окончания ивента из его часового пояса в UTC.
+// Пустой пояс заменяется на DefaultTimezone
+func eventTimes(timezone string, start time.Time, end *time.Time) (string, time.Time, *time.Time, error) {
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+
+	loc, err := LoadLocation(timezone)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	start, err = LocalToUTC(start, loc)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	if end == nil {
+		return timezone, start, nil, nil
+	}
+
+	endUTC, err := LocalToUTC(*end, loc)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	if !endUTC.After(start) {
+		return "", time.Time{}, nil, fmt.Errorf("event end time must be after its start time")
 	}
 
-	return list
+	return timezone, start, &endUTC, nil
 }
@@ -0,0 +1,138 @@
+package event
+
+import (
+	"fmt"
+	"math"
+	"restapi/internal/domain/menu"
+	"sort"
+	"time"
+)
+
+const (
+	// Сколько напитков категории выпьет гость, если для нее ничего не задано
+	defaultDrinksPerGuest = 1.0
+
+	// Погрешность деления порций, чтобы 20.0000001 не округлялось до 21
+	roundingError = 1e-6
+)
+
+// drinksPerGuest дополняет значения по умолчанию значениями ивента
+func drinksPerGuest(defaults, custom map[string]float64) map[string]float64 {
+	perGuest := make(map[string]float64, len(defaults)+len(custom))
+
+	for category, n := range defaults {
+		perGuest[category] = n
+	}
+
+	for category, n := range custom {
+		perGuest[category] = n
+	}
+
+	return perGuest
+}
+
+func validateDrinksPerGuest(perGuest map[string]float64) error {
+	for category, n := range perGuest {
+		if n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+			return fmt.Errorf("drinks per guest for %s must be a non-negative number", category)
+		}
+	}
+
+	return nil
+}
+
+// ShoppingList считает, сколько каждого ингредиента нужно купить на ивент.
+// Гости выпивают perGuest напитков каждой категории, порции делятся поровну между напитками категории.
+// Количества переводятся в базовые единицы (ml, g, pcs), лед считается в граммах отдельно
+// для каждого ice_type, количество округляется вверх
+func ShoppingList(mn menu.Menu, participants uint32, perGuest map[string]float64) []ShoppingItem {
+	amounts := make(map[menu.IngredientKey]float64)
+
+	for category, drinks := range mn.Drinks {
+		if len(drinks) == 0 {
+			continue
+		}
+
+		n, ok := perGuest[category]
+		if !ok {
+			n = defaultDrinksPerGuest
+		}
+
+		// Порций каждого напитка категории
+		servings := float64(participants) * n / float64(len(drinks))
+
+		for _, drink := range drinks {
+			drink.AddIngredients(amounts, servings, drink.OrderIceType)
+		}
+	}
+
+	list := make([]ShoppingItem, 0, len(amounts))
+	for key, amount := range amounts {
+		list = append(list, ShoppingItem{
+			Type:   key.Type,
+			Name:   key.Name,
+			Unit:   key.Unit,
+			Amount: uint32(math.Ceil(amount - roundingError)),
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Type != list[j].Type {
+			return list[i].Type < list[j].Type
+		}
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Unit < list[j].Unit
+	})
+
+	return list
+}
+
+// DiffShoppingLists сравнивает списки покупок по типу, названию и единице ингредиента
+func DiffShoppingLists(from, to []ShoppingItem, at time.Time) ShoppingDiff {
+	diff := ShoppingDiff{
+		Added:     make([]ShoppingItem, 0),
+		Removed:   make([]ShoppingItem, 0),
+		Changed:   make([]ShoppingChange, 0),
+		ChangedAt: at,
+	}
+
+	old := make(map[menu.IngredientKey]uint32, len(from))
+	for _, item := range from {
+		old[item.key()] = item.Amount
+	}
+
+	for _, item := range to {
+		key := item.key()
+
+		amount, ok := old[key]
+		delete(old, key)
+
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, item)
+		case amount != item.Amount:
+			diff.Changed = append(diff.Changed, ShoppingChange{
+				Type: item.Type,
+				Name: item.Name,
+				Unit: item.Unit,
+				From: amount,
+				To:   item.Amount,
+			})
+		}
+	}
+
+	// Оставшиеся позиции есть только в старом списке, порядок берется из него
+	for _, item := range from {
+		if _, ok := old[item.key()]; ok {
+			diff.Removed = append(diff.Removed, item)
+		}
+	}
+
+	return diff
+}
+
+func (i ShoppingItem) key() menu.IngredientKey {
+	return menu.IngredientKey{Type: i.Type, Name: i.Name, Unit: i.Unit}
+}
@@ -0,0 +1,286 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"restapi/internal/domain/menu"
+	"testing"
+	"time"
+)
+
+func TestShoppingList(t *testing.T) {
+	mojito := menu.Drink{
+		ID:           "1",
+		Category:     menu.LongDrink,
+		OrderIceType: menu.CrushedIce,
+		Composition: menu.Composition{
+			IceBulk:    150,
+			Liquids:    []menu.Liquid{{Name: "rum", Unit: "cl", Volume: 5}, {Name: "soda", Unit: "ml", Volume: 100}},
+			SolidsBulk: []menu.SolidBulk{{Name: "sugar", Unit: "g", Volume: 10}},
+			SolidsUnit: []menu.SolidUnit{{Name: "lime", Volume: 1}},
+		},
+	}
+	cubaLibre := menu.Drink{
+		ID:           "2",
+		Category:     menu.LongDrink,
+		OrderIceType: menu.CubedIce,
+		Composition: menu.Composition{
+			IceBulk: 120,
+			Liquids: []menu.Liquid{{Name: "rum", Unit: "ml", Volume: 50}, {Name: "cola", Unit: "l", Volume: 1}},
+		},
+	}
+	beer := menu.Drink{
+		ID:           "3",
+		Category:     menu.Beer,
+		OrderIceType: menu.NoIce,
+		Composition: menu.Composition{
+			IceBulk: 100,
+			Liquids: []menu.Liquid{{Name: "beer", Unit: "ml", Volume: 500}},
+		},
+	}
+	shot := menu.Drink{
+		ID:       "4",
+		Category: menu.ShotDrink,
+		Composition: menu.Composition{
+			Liquids: []menu.Liquid{{Name: "tequila", Unit: "oz", Volume: 1}, {Name: "bitter", Unit: "капля", Volume: 3}},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		drinks       map[string][]menu.Drink
+		participants uint32
+		perGuest     map[string]float64
+		want         []ShoppingItem
+	}{
+		{
+			name:         "empty menu",
+			drinks:       map[string][]menu.Drink{},
+			participants: 10,
+			want:         []ShoppingItem{},
+		},
+		{
+			name:         "one drink per guest by default",
+			drinks:       map[string][]menu.Drink{menu.Beer: {beer}},
+			participants: 3,
+			want: []ShoppingItem{
+				{Type: menu.LiquidType, Name: "beer", Unit: "ml", Amount: 1500},
+			},
+		},
+		{
+			name:         "servings split between drinks of a category",
+			drinks:       map[string][]menu.Drink{menu.LongDrink: {mojito, cubaLibre}},
+			participants: 10,
+			perGuest:     map[string]float64{menu.LongDrink: 2},
+			want: []ShoppingItem{
+				{Type: menu.IceType, Name: menu.CrushedIce, Unit: "g", Amount: 1500},
+				{Type: menu.IceType, Name: menu.CubedIce, Unit: "g", Amount: 1200},
+				{Type: menu.LiquidType, Name: "cola", Unit: "ml", Amount: 10000},
+				{Type: menu.LiquidType, Name: "rum", Unit: "ml", Amount: 1000},
+				{Type: menu.LiquidType, Name: "soda", Unit: "ml", Amount: 1000},
+				{Type: menu.SolidBulkType, Name: "sugar", Unit: "g", Amount: 100},
+				{Type: menu.SolidUnitType, Name: "lime", Unit: "pcs", Amount: 10},
+			},
+		},
+		{
+			name:         "amounts are rounded up",
+			drinks:       map[string][]menu.Drink{menu.LongDrink: {mojito, cubaLibre}},
+			participants: 3,
+			want: []ShoppingItem{
+				{Type: menu.IceType, Name: menu.CrushedIce, Unit: "g", Amount: 225},
+				{Type: menu.IceType, Name: menu.CubedIce, Unit: "g", Amount: 180},
+				{Type: menu.LiquidType, Name: "cola", Unit: "ml", Amount: 1500},
+				{Type: menu.LiquidType, Name: "rum", Unit: "ml", Amount: 150},
+				{Type: menu.LiquidType, Name: "soda", Unit: "ml", Amount: 150},
+				{Type: menu.SolidBulkType, Name: "sugar", Unit: "g", Amount: 15},
+				{Type: menu.SolidUnitType, Name: "lime", Unit: "pcs", Amount: 2},
+			},
+		},
+		{
+			name:         "custom drinks per guest and unknown unit",
+			drinks:       map[string][]menu.Drink{menu.Beer: {beer}, menu.ShotDrink: {shot}},
+			participants: 4,
+			perGuest:     map[string]float64{menu.Beer: 0},
+			want: []ShoppingItem{
+				{Type: menu.LiquidType, Name: "beer", Unit: "ml", Amount: 0},
+				{Type: menu.LiquidType, Name: "bitter", Unit: "капля", Amount: 12},
+				{Type: menu.LiquidType, Name: "tequila", Unit: "ml", Amount: 119},
+			},
+		},
+		{
+			name:         "no guests",
+			drinks:       map[string][]menu.Drink{menu.Beer: {beer}},
+			participants: 0,
+			want: []ShoppingItem{
+				{Type: menu.LiquidType, Name: "beer", Unit: "ml", Amount: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ShoppingList(menu.Menu{Drinks: tt.drinks}, tt.participants, tt.perGuest)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ShoppingList() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffShoppingLists(t *testing.T) {
+	at := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	rum := ShoppingItem{Type: menu.LiquidType, Name: "rum", Unit: "ml", Amount: 1000}
+	lime := ShoppingItem{Type: menu.SolidUnitType, Name: "lime", Unit: "pcs", Amount: 10}
+	ice := ShoppingItem{Type: menu.IceType, Name: menu.CubedIce, Unit: "g", Amount: 1200}
+	// Та же позиция в другой единице - другой ингредиент
+	sugarG := ShoppingItem{Type: menu.SolidBulkType, Name: "sugar", Unit: "g", Amount: 100}
+	sugarML := ShoppingItem{Type: menu.SolidBulkType, Name: "sugar", Unit: "ml", Amount: 100}
+
+	tests := []struct {
+		name    string
+		from    []ShoppingItem
+		to      []ShoppingItem
+		added   []ShoppingItem
+		removed []ShoppingItem
+		changed []ShoppingChange
+	}{
+		{
+			name: "both empty",
+		},
+		{
+			name: "same lists in another order",
+			from: []ShoppingItem{rum, lime, ice},
+			to:   []ShoppingItem{ice, rum, lime},
+		},
+		{
+			name:  "everything added",
+			to:    []ShoppingItem{rum, lime},
+			added: []ShoppingItem{rum, lime},
+		},
+		{
+			name:    "everything removed",
+			from:    []ShoppingItem{rum, lime},
+			removed: []ShoppingItem{rum, lime},
+		},
+		{
+			name: "added, removed and changed",
+			from: []ShoppingItem{ice, rum, sugarG},
+			to: []ShoppingItem{
+				{Type: menu.IceType, Name: menu.CubedIce, Unit: "g", Amount: 1500},
+				lime,
+				rum,
+				sugarML,
+			},
+			added:   []ShoppingItem{lime, sugarML},
+			removed: []ShoppingItem{sugarG},
+			changed: []ShoppingChange{
+				{Type: menu.IceType, Name: menu.CubedIce, Unit: "g", From: 1200, To: 1500},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffShoppingLists(tt.from, tt.to, at)
+
+			want := ShoppingDiff{
+				Added:     append([]ShoppingItem{}, tt.added...),
+				Removed:   append([]ShoppingItem{}, tt.removed...),
+				Changed:   append([]ShoppingChange{}, tt.changed...),
+				ChangedAt: at,
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("DiffShoppingLists() = %+v, want %+v", got, want)
+			}
+
+			if isEmpty := len(tt.added)+len(tt.removed)+len(tt.changed) == 0; got.IsEmpty() != isEmpty {
+				t.Errorf("IsEmpty() = %v, want %v", got.IsEmpty(), isEmpty)
+			}
+		})
+	}
+}
+
+// Хранилища, в которых есть только то, что нужно для пересчета списков покупок
+type testRepository struct {
+	Repository
+	events  []Event
+	fail    string
+	stale   string
+	updated []string
+}
+
+func (r *testRepository) FindMenuEvents(context.Context, string) ([]Event, error) {
+	return r.events, nil
+}
+
+func (r *testRepository) UpdateShoppingList(_ context.Context, id string, _ []ShoppingItem, _ *ShoppingDiff,
+	_ []ShoppingItem) (bool, error) {
+	if id == r.fail {
+		return false, errors.New("database updating error")
+	}
+
+	if id == r.stale {
+		return false, nil
+	}
+
+	r.updated = append(r.updated, id)
+	return true, nil
+}
+
+type testMenuRepository struct {
+	menu.Repository
+	menu menu.Menu
+}
+
+func (r testMenuRepository) FindMenu(context.Context, menu.FindMenuDTO) (menu.Menu, error) {
+	return r.menu, nil
+}
+
+func TestMenuChanged(t *testing.T) {
+	mn := menu.Menu{ID: "menu", Drinks: map[string][]menu.Drink{
+		menu.Beer: {{ID: "beer", Composition: menu.Composition{
+			Liquids: []menu.Liquid{{Name: "beer", Unit: "ml", Volume: 500}},
+		}}},
+	}}
+	events := []Event{
+		{ID: "1", MenuID: "menu", ParticipantsNumber: 10},
+		{ID: "2", MenuID: "menu", ParticipantsNumber: 20},
+		{ID: "3", MenuID: "menu", ParticipantsNumber: 30},
+	}
+
+	tests := []struct {
+		name    string
+		fail    string
+		stale   string
+		updated []string
+		wantErr bool
+	}{
+		{"all events are refreshed", "", "", []string{"1", "2", "3"}, false},
+		{"failed event does not stop the rest", "2", "", []string{"1", "3"}, true},
+		{"stale event is skipped", "", "3", []string{"1", "2"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &testRepository{events: events, fail: tt.fail, stale: tt.stale}
+			s := &service{
+				repository: repo,
+				menuRepos:  testMenuRepository{menu: mn},
+				logger:     testLogger(),
+			}
+
+			err := s.MenuChanged(context.Background(), "menu")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MenuChanged() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(repo.updated, tt.updated) {
+				t.Errorf("updated events = %v, want %v", repo.updated, tt.updated)
+			}
+		})
+	}
+}
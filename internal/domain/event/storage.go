@@ -1,14 +1,27 @@
 package event
 
-import "context"
+import (
+	"context"
+	"restapi/internal/domain/menu"
+	"restapi/internal/domain/report"
+	"time"
+)
 
 type Repository interface {
-	CreateEvent(context.Context, CreateEventDTO, []string) (string, error)
-	SetActive(context.Context, string) error
+	CreateEvent(context.Context, CreateEventDTO, []ShoppingItem) (string, error)
+	SetActive(context.Context, string, time.Time) (bool, error)
+	FindScheduledEvents(context.Context) ([]Event, error)
 	FindAllUserEvents(context.Context, FindAllEventsDTO) (RespAllEvents, error)
 	FindUserEvent(context.Context, FindEventDTO) (Event, error)
-	UpdateEvent(context.Context, UpdateEventDTO) error
-	DeleteEvent(context.Context, CompleteEventDTO) error
+	FindEvent(context.Context, string) (Event, error)
+	FindArchivedEvents(context.Context, FindArchiveDTO) (RespAllEvents, error)
+	UpdateEvent(context.Context, UpdateEventDTO) (Event, error)
+	UpdateShoppingList(ctx context.Context, id string, list []ShoppingItem, diff *ShoppingDiff, reviewed []ShoppingItem) (bool, error)
+	ReviewShoppingList(context.Context, ReviewShoppingDTO) error
+	FindMenuEvents(context.Context, string) ([]Event, error)
+	CompleteEvent(context.Context, string, menu.Menu, report.Report, *time.Time) (bool, error)
+	CancelEvent(context.Context, CancelEventDTO) (bool, error)
+	FindPurchases(context.Context, string) ([]report.Purchase, error)
 	UpdateIceTypesNum(context.Context, bool, string) error
 	GetIceTypesNum(context.Context, string) (bool, error)
 }
@@ -1,5 +1,11 @@
 package ingredients
 
+import (
+	"fmt"
+	"restapi/internal/domain/menu"
+	"restapi/pkg/units"
+)
+
 const (
 	// ice_types
 	BlockIce   = "block_ice"
@@ -10,16 +16,16 @@ const (
 	NoIce      = "no_ice"
 
 	// ingredient_types
-	IceType       = "ice"
-	LiquidType    = "liquids"
-	SolidBulkType = "solids_bulk"
-	SolidUnitType = "solids_unit"
+	IceType       = menu.IceType
+	LiquidType    = menu.LiquidType
+	SolidBulkType = menu.SolidBulkType
+	SolidUnitType = menu.SolidUnitType
 )
 
 // type - тип ингридиента
 // У типа IceType поле Name содержит ice_type
 // Ингридиентов с IceType типом может быть несколько с уникальным name
-// У ингридиентов типа SolidUnitType поле unit - пустое?, а в поле volume(объем) указывается количество в штуках
+// У ингридиентов типа SolidUnitType поле unit - pcs, а в поле volume(объем) указывается количество в штуках
 type Ingredient struct {
 	ID      string `json:"id"`
 	UserID  string `json:"user_id"`
@@ -30,3 +36,33 @@ type Ingredient struct {
 	Volume  uint32 `json:"volume"`
 	Cost    uint32 `json:"cost"`
 }
+
+// NormalizeUnit приводит единицу ингредиента к канонической из units и проверяет,
+// что она подходит типу: жидкости - объем, лед - масса, сыпучие - масса или объем, штучные - pcs.
+// Расход льда в рецептах считается в граммах, поэтому лед в объеме нельзя было бы оценить
+func NormalizeUnit(ingrType, unit string) (string, error) {
+	var dims []units.Dimension
+
+	switch ingrType {
+	case LiquidType:
+		dims = []units.Dimension{units.Volume}
+	case IceType:
+		dims = []units.Dimension{units.Mass}
+	case SolidBulkType:
+		dims = []units.Dimension{units.Mass, units.Volume}
+	case SolidUnitType:
+		if unit == "" {
+			return string(units.Pcs), nil
+		}
+		dims = []units.Dimension{units.Count}
+	default:
+		return "", fmt.Errorf("unknown ingredient type: %s", ingrType)
+	}
+
+	u, err := units.ParseAs(unit, dims...)
+	if err != nil {
+		return "", err
+	}
+
+	return string(u), nil
+}
@@ -0,0 +1,37 @@
+package ingredients
+
+import "testing"
+
+func TestNormalizeUnit(t *testing.T) {
+	tests := []struct {
+		ingrType string
+		unit     string
+		want     string
+		wantErr  bool
+	}{
+		{LiquidType, "мл", "ml", false},
+		{LiquidType, "kg", "", true},
+		{IceType, "кг", "kg", false},
+		{IceType, "g", "g", false},
+		{IceType, "l", "", true},
+		{SolidBulkType, "g", "g", false},
+		{SolidBulkType, "ml", "ml", false},
+		{SolidBulkType, "pcs", "", true},
+		{SolidUnitType, "", "pcs", false},
+		{SolidUnitType, "шт", "pcs", false},
+		{SolidUnitType, "g", "", true},
+		{"spices", "g", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeUnit(tt.ingrType, tt.unit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeUnit(%s, %q) error = %v, wantErr %v", tt.ingrType, tt.unit, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("NormalizeUnit(%s, %q) = %s, want %s", tt.ingrType, tt.unit, got, tt.want)
+		}
+	}
+}
@@ -36,6 +36,14 @@ func NewService(repository Repository, eventRepos event.Repository, logger *logg
 func (s *service) NewIngredients(ctx context.Context, dto AddIngredientsDTO) error {
 	s.logger.Infof("creating list of event ingredients")
 
+	for i, ingr := range dto.Ingredients {
+		unit, err := NormalizeUnit(ingr.Type, ingr.Unit)
+		if err != nil {
+			return fmt.Errorf("ingredient %s: %v", ingr.Name, err)
+		}
+		dto.Ingredients[i].Unit = unit
+	}
+
 	err := s.eventRepos.UpdateIceTypesNum(ctx, dto.OnlyOneIceType, dto.EventID)
 	if err != nil {
 		return fmt.Errorf("finding event error: %v", err)
@@ -58,6 +66,12 @@ func (s *service) NewIngredients(ctx context.Context, dto AddIngredientsDTO) err
 func (s *service) AddIngredient(ctx context.Context, dto AddIngredientDTO) error {
 	s.logger.Infof("adding ingredient %s", dto.Name)
 
+	unit, err := NormalizeUnit(dto.Type, dto.Unit)
+	if err != nil {
+		return err
+	}
+	dto.Unit = unit
+
 	ingrID, err := s.repository.AddIngredient(ctx, dto)
 
 	if err != nil {
@@ -130,7 +144,13 @@ func (s *service) FindEventIngredients(ctx context.Context, dto FindEventIngredi
 func (s *service) UpdateIngredient(ctx context.Context, dto UpdateIngredientDTO) error {
 	s.logger.Infof("update ingredient")
 
-	err := s.repository.UpdateIngredient(ctx, dto)
+	unit, err := NormalizeUnit(dto.Type, dto.Unit)
+	if err != nil {
+		return err
+	}
+	dto.Unit = unit
+
+	err = s.repository.UpdateIngredient(ctx, dto)
 
 	if err != nil {
 		return err
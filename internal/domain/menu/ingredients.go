@@ -0,0 +1,51 @@
+package menu
+
+import "restapi/pkg/units"
+
+const (
+	// ingredient_types, как в ingredients
+	IceType       = "ice"
+	LiquidType    = "liquids"
+	SolidBulkType = "solids_bulk"
+	SolidUnitType = "solids_unit"
+)
+
+// Ингредиент рецепта: тип, название (у льда - ice_type) и единица.
+// По нему складываются количества в списке покупок и расход в отчете
+type IngredientKey struct {
+	Type string
+	Name string
+	Unit string
+}
+
+// AddIngredients прибавляет к amounts ингредиенты servings порций напитка в базовых единицах.
+// Лед считается в граммах по типу ice, без типа или с NoIce не считается
+func (d Drink) AddIngredients(amounts map[IngredientKey]float64, servings float64, ice string) {
+	for _, liq := range d.Composition.Liquids {
+		AddAmount(amounts, LiquidType, liq.Name, liq.Unit, float64(liq.Volume)*servings)
+	}
+
+	for _, solB := range d.Composition.SolidsBulk {
+		AddAmount(amounts, SolidBulkType, solB.Name, solB.Unit, float64(solB.Volume)*servings)
+	}
+
+	for _, solU := range d.Composition.SolidsUnit {
+		AddAmount(amounts, SolidUnitType, solU.Name, string(units.Pcs), float64(solU.Volume)*servings)
+	}
+
+	if ice != "" && ice != NoIce && d.Composition.IceBulk > 0 {
+		AddAmount(amounts, IceType, ice, string(units.G), float64(d.Composition.IceBulk)*servings)
+	}
+}
+
+// AddAmount прибавляет количество ингредиента в базовой единице.
+// Единица, которой нет в units (рецепты, сохраненные до проверки единиц), остается как есть
+func AddAmount(amounts map[IngredientKey]float64, ingrType, name, unit string, amount float64) {
+	normalized, base, err := units.Normalize(amount, unit)
+	if err != nil {
+		amounts[IngredientKey{ingrType, name, unit}] += amount
+		return
+	}
+
+	amounts[IngredientKey{ingrType, name, string(base)}] += normalized
+}
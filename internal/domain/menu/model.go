@@ -1,5 +1,10 @@
 package menu
 
+import (
+	"fmt"
+	"restapi/pkg/units"
+)
+
 const (
 	// Drinks.Category constants
 	Beer       = "beers"
@@ -45,7 +50,7 @@ type Drink struct {
 }
 
 // Состав напитка:
-// общее количества затраченного льда, ингридиенты разных типов с количеством и размерностью
+// общее количества затраченного льда в граммах, ингридиенты разных типов с количеством и размерностью
 type Composition struct {
 	IceBulk    uint32      `json:"ice_bulk"`
 	Liquids    []Liquid    `json:"liquids,omitempty"`
@@ -72,3 +77,38 @@ type SolidUnit struct {
 	Name   string `json:"name"`
 	Volume uint32 `json:"volume"`
 }
+
+// Normalize приводит единицы состава к каноническим из units и проверяет их:
+// жидкости измеряются объемом, твердые ингредиенты - массой или объемом
+func (c *Composition) Normalize() error {
+	for i, liq := range c.Liquids {
+		unit, err := units.ParseAs(liq.Unit, units.Volume)
+		if err != nil {
+			return fmt.Errorf("liquid %s: %v", liq.Name, err)
+		}
+		c.Liquids[i].Unit = string(unit)
+	}
+
+	for i, solB := range c.SolidsBulk {
+		unit, err := units.ParseAs(solB.Unit, units.Mass, units.Volume)
+		if err != nil {
+			return fmt.Errorf("solid %s: %v", solB.Name, err)
+		}
+		c.SolidsBulk[i].Unit = string(unit)
+	}
+
+	return nil
+}
+
+// DrinksByID возвращает напитки меню всех категорий по их id
+func (m Menu) DrinksByID() map[string]Drink {
+	drinks := make(map[string]Drink)
+
+	for _, group := range m.Drinks {
+		for _, drink := range group {
+			drinks[drink.ID] = drink
+		}
+	}
+
+	return drinks
+}
@@ -18,14 +18,21 @@ type Service interface {
 	DeleteDrink(context.Context, DeleteDrinkDTO) error
 }
 
+// Listener узнает об изменениях напитков меню, например, чтобы пересчитать списки покупок ивентов
+type Listener interface {
+	MenuChanged(ctx context.Context, menuID string) error
+}
+
 type service struct {
 	repository Repository
+	listener   Listener
 	logger     *logging.Logger
 }
 
-func NewService(repository Repository, logger *logging.Logger) Service {
+func NewService(repository Repository, listener Listener, logger *logging.Logger) Service {
 	return &service{
 		repository: repository,
+		listener:   listener,
 		logger:     logger,
 	}
 }
@@ -41,6 +48,10 @@ func (s *service) NewMenu(ctx context.Context, dto CreateMenuDTO) (string, error
 	drMap := make(map[string][]NewDrinkDTO, 0)
 
 	for _, drink := range drinks {
+		if err := drink.Composition.Normalize(); err != nil {
+			return "", fmt.Errorf("drink %s: %v", drink.Name, err)
+		}
+
 		newDr := NewDrinkDTO{
 			Name:           drink.Name,
 			Category:       drink.Category,
@@ -119,6 +130,14 @@ func (s *service) FindUserMenus(ctx context.Context, dto UserMenusDTO) (RespUser
 func (s *service) UpdateMenu(ctx context.Context, dto UpdateMenuDTO) error {
 	s.logger.Infof("update menu")
 
+	for _, drinks := range dto.Drinks {
+		for i := range drinks {
+			if err := drinks[i].Composition.Normalize(); err != nil {
+				return fmt.Errorf("drink %s: %v", drinks[i].Name, err)
+			}
+		}
+	}
+
 	totalCost := s.UpdateTotalCost(dto.Drinks)
 
 	updatedID, err := s.repository.UpdateMenu(ctx, dto, totalCost)
@@ -129,6 +148,8 @@ func (s *service) UpdateMenu(ctx context.Context, dto UpdateMenuDTO) error {
 
 	s.logger.Infof("menu %s is updated", updatedID)
 
+	s.menuChanged(ctx, dto.ID)
+
 	return nil
 }
 
@@ -149,6 +170,10 @@ func (s *service) UpdateMenuName(ctx context.Context, dto UpdateMenuNameDTO) err
 func (s *service) AddDrink(ctx context.Context, dto AddDrinkDTO) (Drink, error) {
 	s.logger.Infof("adding drink to menu %s", dto.MenuID)
 
+	if err := dto.Drink.Composition.Normalize(); err != nil {
+		return Drink{}, err
+	}
+
 	drinkID, err := s.repository.AddDrink(ctx, dto)
 
 	if err != nil {
@@ -168,6 +193,8 @@ func (s *service) AddDrink(ctx context.Context, dto AddDrinkDTO) (Drink, error)
 
 	s.logger.Infof("drink added to menu")
 
+	s.menuChanged(ctx, dto.MenuID)
+
 	return dr, nil
 }
 
@@ -179,6 +206,10 @@ func (s *service) AddDrinkFromList(ctx context.Context, dto AddDrinkFromListDTO)
 		return Drink{}, fmt.Errorf("finding in drink list error: %v", err)
 	}
 
+	if err := newDrDTO.Composition.Normalize(); err != nil {
+		return Drink{}, err
+	}
+
 	AddDrinkDTO := AddDrinkDTO{
 		MenuID: dto.MenuID,
 		Drink:  newDrDTO,
@@ -203,22 +234,34 @@ func (s *service) AddDrinkFromList(ctx context.Context, dto AddDrinkFromListDTO)
 
 	s.logger.Infof("drink from drink list added to menu")
 
+	s.menuChanged(ctx, dto.MenuID)
+
 	return dr, nil
 }
 
 func (s *service) DeleteDrink(ctx context.Context, dto DeleteDrinkDTO) error {
 	s.logger.Infof("deleting drink from menu")
 
-	err := s.repository.DeleteDrink(ctx, dto)
+	menuID, err := s.repository.DeleteDrink(ctx, dto)
 	if err != nil {
 		return err
 	}
 
 	s.logger.Infof("drink deleted from menu")
 
+	s.menuChanged(ctx, menuID)
+
 	return nil
 }
 
+// menuChanged сообщает об изменении меню. Меню уже сохранено,
+// поэтому ошибка слушателя только логируется
+func (s *service) menuChanged(ctx context.Context, menuID string) {
+	if err := s.listener.MenuChanged(ctx, menuID); err != nil {
+		s.logger.Errorf("menu %s change handling error: %v", menuID, err)
+	}
+}
+
 func (s *service) UpdateTotalCost(drinkGroups map[string][]Drink) uint32 {
 	var totalCost uint32
 	for _, drinks := range drinkGroups {
@@ -11,6 +11,6 @@ type Repository interface {
 	UpdateMenu(context.Context, UpdateMenuDTO, uint32) (string, error)
 	UpdateNameMenu(context.Context, UpdateMenuNameDTO) error
 	AddDrink(context.Context, AddDrinkDTO) (string, error)
-	DeleteDrink(context.Context, DeleteDrinkDTO) error
+	DeleteDrink(context.Context, DeleteDrinkDTO) (string, error)
 	FindUserDrink(context.Context, string) (NewDrinkDTO, error)
 }
@@ -1,6 +1,110 @@
 package order
 
+import "time"
+
+// Если TabID указан, стоимость заказа начисляется на этот открытый счет гостя.
+// TabSecret - секрет, выданный гостю при открытии счета
 type CreateOrderDTO struct {
-	BarID     string    `json:"bar_id"`
-	OrderBody OrderBody `json:"order_body"`
+	BarID     uint32      `json:"bar_id"`
+	TabID     string      `json:"tab_id,omitempty"`
+	TabSecret string      `json:"tab_secret,omitempty"`
+	Items     []OrderItem `json:"items"`
+	Comment   string      `json:"comment"`
+}
+
+type FindOrderDTO struct {
+	ID string `json:"id"`
+}
+
+type FindBarOrdersDTO struct {
+	BarID   uint32 `json:"bar_id"`
+	EventID string `json:"event_id"`
+}
+
+type FindEventOrdersDTO struct {
+	EventID string `json:"event_id"`
+}
+
+// Записи истории заказов бара с номером больше AfterSeq, не больше Limit записей
+type FindBarHistoryDTO struct {
+	BarID    uint32 `json:"bar_id"`
+	EventID  string `json:"event_id"`
+	AfterSeq uint64 `json:"after_seq"`
+	Limit    int    `json:"limit"`
+}
+
+type ChangeStatusDTO struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	UserID string `json:"-"`
+}
+
+type FindStuckOrdersDTO struct {
+	BarID   uint32        `json:"bar_id"`
+	EventID string        `json:"event_id"`
+	Timeout time.Duration `json:"timeout"`
+}
+
+type RespStuckOrder struct {
+	Order          Order   `json:"order"`
+	WaitingSeconds float64 `json:"waiting_seconds"`
+}
+
+type RespStageStats struct {
+	BarID  uint32      `json:"bar_id"`
+	Stages []StageStat `json:"stages"`
+}
+
+// Среднее время, которое заказы бара проводят в статусе
+type StageStat struct {
+	Status     string  `json:"status"`
+	Orders     int     `json:"orders"`
+	AvgSeconds float64 `json:"avg_seconds"`
+}
+
+// Window - за какой период считаются заказы в минуту и среднее время подачи,
+// Slowest - сколько самых долгих открытых заказов вернуть
+type FindThroughputDTO struct {
+	BarID   uint32        `json:"bar_id"`
+	EventID string        `json:"event_id"`
+	Window  time.Duration `json:"window"`
+	Slowest int           `json:"slowest"`
+}
+
+// Текущая загрузка бара
+type RespThroughput struct {
+	BarID           uint32      `json:"bar_id"`
+	WindowMinutes   float64     `json:"window_minutes"`
+	OrdersPerMinute float64     `json:"orders_per_minute"`
+	QueueLength     int         `json:"queue_length"`
+	ServedOrders    int         `json:"served_orders"`
+	AvgServeSeconds float64     `json:"avg_serve_seconds"`
+	SlowestOrders   []SlowOrder `json:"slowest_orders"`
+	ComputedAt      time.Time   `json:"computed_at"`
+}
+
+// Открытый заказ и сколько секунд прошло с его размещения
+type SlowOrder struct {
+	OrderID    string  `json:"order_id"`
+	Status     string  `json:"status"`
+	AgeSeconds float64 `json:"age_seconds"`
+}
+
+// Заказ в очереди бармена с расписанными позициями
+type QueueOrder struct {
+	OrderID  string      `json:"order_id"`
+	Status   string      `json:"status"`
+	PlacedAt time.Time   `json:"placed_at"`
+	Comment  string      `json:"comment,omitempty"`
+	Total    uint32      `json:"total"`
+	Lines    []QueueLine `json:"lines"`
+}
+
+type QueueLine struct {
+	DrinkID       string `json:"drink_id"`
+	Name          string `json:"name"`
+	CookingMethod string `json:"cooking_method"`
+	Quantity      uint32 `json:"quantity"`
+	IceType       string `json:"ice_type"`
+	Note          string `json:"note,omitempty"`
 }
@@ -1,15 +1,232 @@
 package order
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"restapi/internal/domain/menu"
+	"time"
+)
+
+const (
+	// order statuses
+	StatusPlaced    = "placed"
+	StatusAccepted  = "accepted"
+	StatusPreparing = "preparing"
+	StatusReady     = "ready"
+	StatusServed    = "served"
+	StatusCancelled = "cancelled"
+	StatusRejected  = "rejected"
+
+	// статус закрытого бара, в который нельзя сделать заказ
+	barStatusClosed = "Closed"
+
+	// наибольшее число порций одного напитка в заказе
+	MaxQuantity = 100
+)
+
+// Допустимые переходы между статусами заказа.
+// Served, Cancelled и Rejected - конечные статусы
+var transitions = map[string][]string{
+	StatusPlaced:    {StatusAccepted, StatusCancelled, StatusRejected},
+	StatusAccepted:  {StatusPreparing, StatusCancelled, StatusRejected},
+	StatusPreparing: {StatusReady, StatusCancelled},
+	StatusReady:     {StatusServed, StatusCancelled},
+}
 
 type Order struct {
-	ID        string    `json:"id"`
-	BarID     string    `json:"bar_id"`
-	OrderBody OrderBody `json:"order_body"`
-	DateTime  time.Time `json:"date_time"`
+	ID        string         `json:"id"`
+	EventID   string         `json:"event_id"`
+	BarID     uint32         `json:"bar_id"`
+	TabID     string         `json:"tab_id,omitempty"`
+	Items     []OrderItem    `json:"items"`
+	Comment   string         `json:"comment"`
+	Total     uint32         `json:"total"`
+	Status    string         `json:"status"`
+	History   []StatusChange `json:"history"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Позиция заказа: напиток, количество порций и пожелания к ним.
+// IceType переопределяет menu.Drink.OrderIceType, пустой - лед по рецепту.
+// Name, Category и Price (цена порции) копируются из меню при размещении заказа,
+// поэтому отчеты не меняются, если напиток потом изменили или удалили из меню.
+// У заказов, сделанных раньше, эти поля пустые
+type OrderItem struct {
+	DrinkID  string `json:"drink_id"`
+	Name     string `json:"name,omitempty"`
+	Category string `json:"category,omitempty"`
+	Price    uint32 `json:"price,omitempty"`
+	Quantity uint32 `json:"quantity"`
+	IceType  string `json:"ice_type,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// Бар, в который делается заказ, и меню ивента, к которому он относится.
+// UnavailableDrinks - напитки меню, которые сейчас нельзя заказать в баре
+type OrderBar struct {
+	BarID             uint32
+	EventID           string
+	MenuID            string
+	BarStatus         string
+	UnavailableDrinks []string
+}
+
+// Запись о смене статуса заказа: кто и когда перевел заказ из From в To.
+// Первая запись истории (размещение заказа) имеет пустой From.
+// Seq - порядковый номер записи среди всех записей истории заказов бара
+type StatusChange struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+	Seq       uint64    `json:"seq"`
+}
+
+// Запись истории заказов бара и заказ в том статусе, в который его перевела эта запись
+type HistoryEvent struct {
+	Change StatusChange
+	Order  Order
+}
+
+// Загрузка бара, посчитанная хранилищем без загрузки истории заказов.
+// Placed и Served - сколько заказов размещено и подано за период статистики,
+// ServeSeconds - суммарное время от размещения до подачи поданных заказов,
+// Open - самые старые открытые заказы
+type BarLoad struct {
+	Placed       int
+	Served       int
+	ServeSeconds float64
+	QueueLength  int
+	Open         []Order
+}
+
+// ItemDrink возвращает название, категорию и цену порции напитка на момент заказа.
+// У позиций заказов, сделанных до того, как их стали запоминать, они берутся из меню
+func ItemDrink(item OrderItem, drink menu.Drink) (string, string, uint32) {
+	if item.Name == "" {
+		return drink.Name, drink.Category, drink.Price
+	}
+
+	return item.Name, item.Category, item.Price
+}
+
+// CountTotal считает стоимость позиций заказа по ценам напитков.
+// Возвращает ошибку, если стоимость не помещается в uint32
+func CountTotal(items []OrderItem, prices map[string]uint32) (uint32, error) {
+	var total uint64
+	for _, item := range items {
+		total += uint64(prices[item.DrinkID]) * uint64(item.Quantity)
+		if total > math.MaxUint32 {
+			return 0, fmt.Errorf("order total is too large")
+		}
+	}
+	return uint32(total), nil
+}
+
+// IsRefunded сообщает, что заказ в этом статусе не оплачивается
+// и начисление за него снимается со счета гостя
+func IsRefunded(status string) bool {
+	return status == StatusCancelled || status == StatusRejected
+}
+
+// OpenStatuses возвращает статусы, в которых заказ еще не завершен
+func OpenStatuses() []string {
+	statuses := make([]string, 0, len(transitions))
+	for status := range transitions {
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func IsFinal(status string) bool {
+	_, ok := transitions[status]
+	return !ok
+}
+
+func IsValidStatus(status string) bool {
+	switch status {
+	case StatusPlaced, StatusAccepted, StatusPreparing, StatusReady,
+		StatusServed, StatusCancelled, StatusRejected:
+		return true
+	}
+	return false
+}
+
+// SetStatus переводит заказ в новый статус, если такой переход допустим,
+// и добавляет запись в историю
+func (o *Order) SetStatus(status, changedBy string, at time.Time) (StatusChange, error) {
+	if !IsValidStatus(status) {
+		return StatusChange{}, fmt.Errorf("unknown order status: %s", status)
+	}
+
+	allowed := false
+	for _, next := range transitions[o.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return StatusChange{}, fmt.Errorf("order status can't be changed from %s to %s", o.Status, status)
+	}
+
+	change := StatusChange{
+		From:      o.Status,
+		To:        status,
+		ChangedBy: changedBy,
+		ChangedAt: at,
+	}
+
+	o.Status = status
+	o.UpdatedAt = at
+	o.History = append(o.History, change)
+
+	return change, nil
 }
 
-type OrderBody struct {
-	DrinksID []string `json:"drinks_id"`
-	Comment  string   `json:"comment"`
+// LastSeq возвращает номер последней записи истории заказа
+func (o Order) LastSeq() uint64 {
+	if len(o.History) == 0 {
+		return 0
+	}
+
+	return o.History[len(o.History)-1].Seq
+}
+
+// StatusSince возвращает время, с которого заказ находится в текущем статусе
+func (o Order) StatusSince() time.Time {
+	if len(o.History) == 0 {
+		return o.CreatedAt
+	}
+
+	return o.History[len(o.History)-1].ChangedAt
+}
+
+// ServedAt возвращает время подачи заказа, если он подан
+func (o Order) ServedAt() (time.Time, bool) {
+	for _, change := range o.History {
+		if change.To == StatusServed {
+			return change.ChangedAt, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// StageDurations считает, сколько времени заказ провел в каждом из пройденных статусов.
+// Текущий статус не учитывается, так как он еще не завершен
+func (o Order) StageDurations() map[string]time.Duration {
+	durations := make(map[string]time.Duration, len(o.History))
+
+	prev := o.CreatedAt
+	for _, change := range o.History {
+		if change.From != "" {
+			durations[change.From] += change.ChangedAt.Sub(prev)
+		}
+		prev = change.ChangedAt
+	}
+
+	return durations
 }
@@ -0,0 +1,146 @@
+package order
+
+import (
+	"math"
+	"restapi/internal/domain/menu"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSetStatus(t *testing.T) {
+	at := time.Date(2024, 7, 1, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		from    string
+		to      string
+		wantErr bool
+	}{
+		{StatusPlaced, StatusAccepted, false},
+		{StatusPlaced, StatusCancelled, false},
+		{StatusPlaced, StatusRejected, false},
+		{StatusPlaced, StatusReady, true},
+		{StatusAccepted, StatusPreparing, false},
+		{StatusAccepted, StatusRejected, false},
+		{StatusAccepted, StatusServed, true},
+		{StatusPreparing, StatusReady, false},
+		{StatusPreparing, StatusCancelled, false},
+		{StatusPreparing, StatusRejected, true},
+		{StatusReady, StatusServed, false},
+		{StatusReady, StatusCancelled, false},
+		{StatusReady, StatusPlaced, true},
+		{StatusServed, StatusCancelled, true},
+		{StatusCancelled, StatusPlaced, true},
+		{StatusRejected, StatusAccepted, true},
+		{StatusPlaced, "lost", true},
+	}
+
+	for _, tt := range tests {
+		ordr := Order{Status: tt.from}
+
+		change, err := ordr.SetStatus(tt.to, "user", at)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s -> %s: error = %v, wantErr %v", tt.from, tt.to, err, tt.wantErr)
+			continue
+		}
+
+		if tt.wantErr {
+			if ordr.Status != tt.from || len(ordr.History) != 0 {
+				t.Errorf("%s -> %s: order is changed by a rejected transition", tt.from, tt.to)
+			}
+			continue
+		}
+
+		want := StatusChange{From: tt.from, To: tt.to, ChangedBy: "user", ChangedAt: at}
+		if change != want || ordr.Status != tt.to || !ordr.UpdatedAt.Equal(at) ||
+			len(ordr.History) != 1 || ordr.History[0] != want {
+			t.Errorf("%s -> %s: order = %+v, change = %+v", tt.from, tt.to, ordr, change)
+		}
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	tests := []struct {
+		status string
+		want   bool
+	}{
+		{StatusPlaced, false},
+		{StatusAccepted, false},
+		{StatusPreparing, false},
+		{StatusReady, false},
+		{StatusServed, true},
+		{StatusCancelled, true},
+		{StatusRejected, true},
+	}
+
+	for _, tt := range tests {
+		if got := IsFinal(tt.status); got != tt.want {
+			t.Errorf("IsFinal(%s) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+
+	open := OpenStatuses()
+	sort.Strings(open)
+	want := []string{StatusAccepted, StatusPlaced, StatusPreparing, StatusReady}
+	if len(open) != len(want) {
+		t.Fatalf("OpenStatuses() = %v, want %v", open, want)
+	}
+	for i := range want {
+		if open[i] != want[i] {
+			t.Fatalf("OpenStatuses() = %v, want %v", open, want)
+		}
+	}
+}
+
+func TestCountTotal(t *testing.T) {
+	prices := map[string]uint32{"beer": 300, "mojito": 500, "gold": math.MaxUint32}
+
+	tests := []struct {
+		name    string
+		items   []OrderItem
+		want    uint32
+		wantErr bool
+	}{
+		{"empty order", nil, 0, false},
+		{"one item", []OrderItem{{DrinkID: "beer", Quantity: 2}}, 600, false},
+		{"several items", []OrderItem{{DrinkID: "beer", Quantity: 2}, {DrinkID: "mojito", Quantity: 3}}, 2100, false},
+		{"unknown drink is free", []OrderItem{{DrinkID: "water", Quantity: 1}}, 0, false},
+		{"exactly max", []OrderItem{{DrinkID: "gold", Quantity: 1}}, math.MaxUint32, false},
+		{"item overflows", []OrderItem{{DrinkID: "gold", Quantity: 2}}, 0, true},
+		{"sum overflows", []OrderItem{{DrinkID: "gold", Quantity: 1}, {DrinkID: "beer", Quantity: 1}}, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := CountTotal(tt.items, prices)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%s: CountTotal() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestItemDrink(t *testing.T) {
+	drink := menu.Drink{Name: "Mojito", Category: menu.LongDrink, Price: 500}
+
+	tests := []struct {
+		name         string
+		item         OrderItem
+		wantName     string
+		wantCategory string
+		wantPrice    uint32
+	}{
+		{"stored on the item", OrderItem{Name: "Old mojito", Category: menu.ShortDrink, Price: 450}, "Old mojito", menu.ShortDrink, 450},
+		{"legacy item falls back to the menu", OrderItem{}, "Mojito", menu.LongDrink, 500},
+	}
+
+	for _, tt := range tests {
+		name, category, price := ItemDrink(tt.item, drink)
+		if name != tt.wantName || category != tt.wantCategory || price != tt.wantPrice {
+			t.Errorf("%s: ItemDrink() = %s, %s, %d", tt.name, name, category, price)
+		}
+	}
+}
@@ -0,0 +1,474 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"restapi/internal/domain/menu"
+	"restapi/internal/domain/tab"
+	"restapi/pkg/logging"
+	"sort"
+	"time"
+)
+
+type Service interface {
+	NewOrder(context.Context, CreateOrderDTO) (Order, error)
+	FindOrder(context.Context, FindOrderDTO) (Order, error)
+	FindBarOrders(context.Context, FindBarOrdersDTO) ([]Order, error)
+	FindEventOrders(context.Context, FindEventOrdersDTO) ([]Order, error)
+	ChangeStatus(context.Context, ChangeStatusDTO) (Order, error)
+	FindStuckOrders(context.Context, FindStuckOrdersDTO) ([]RespStuckOrder, error)
+	GetStageStats(context.Context, FindBarOrdersDTO) (RespStageStats, error)
+	GetThroughput(context.Context, FindThroughputDTO) (RespThroughput, error)
+	GetBarQueue(context.Context, FindBarOrdersDTO) ([]QueueOrder, error)
+	FindBarHistory(context.Context, FindBarHistoryDTO) ([]HistoryEvent, error)
+	Validate(context.Context, CreateOrderDTO) error
+}
+
+type service struct {
+	repository Repository
+	menuRepos  menu.Repository
+	tabRepos   tab.Repository
+	logger     *logging.Logger
+}
+
+func NewService(repository Repository, menuRepos menu.Repository, tabRepos tab.Repository,
+	logger *logging.Logger) Service {
+	return &service{
+		repository: repository,
+		menuRepos:  menuRepos,
+		tabRepos:   tabRepos,
+		logger:     logger,
+	}
+}
+
+func (s *service) NewOrder(ctx context.Context, dto CreateOrderDTO) (Order, error) {
+	s.logger.Infof("creating order to bar %d", dto.BarID)
+
+	drinks, err := s.orderDrinks(ctx, dto)
+	if err != nil {
+		return Order{}, err
+	}
+
+	prices := make(map[string]uint32, len(drinks))
+	for id, drink := range drinks {
+		prices[id] = drink.Price
+	}
+
+	// Позиции запоминают напиток таким, каким он был в меню в момент заказа.
+	// Срез вызывающего не меняется
+	items := make([]OrderItem, len(dto.Items))
+	for i, item := range dto.Items {
+		drink := drinks[item.DrinkID]
+		item.Name = drink.Name
+		item.Category = drink.Category
+		item.Price = drink.Price
+		items[i] = item
+	}
+	dto.Items = items
+
+	total, err := CountTotal(dto.Items, prices)
+	if err != nil {
+		return Order{}, err
+	}
+
+	ordr, err := s.repository.CreateOrder(ctx, dto, total)
+
+	if err != nil {
+		return Order{}, err
+	}
+
+	if ordr.TabID != "" {
+		s.logger.Infof("order %s is charged to tab %s: %d", ordr.ID, ordr.TabID, ordr.Total)
+	}
+
+	s.logger.Infof("order is created, order_id: %s", ordr.ID)
+
+	return ordr, nil
+}
+
+// Validate проверяет, что бар открыт, а каждый заказанный напиток есть в меню ивента бара,
+// подается именно в этом баре и сейчас доступен
+func (s *service) Validate(ctx context.Context, dto CreateOrderDTO) error {
+	_, err := s.orderDrinks(ctx, dto)
+	return err
+}
+
+// orderDrinks проверяет заказ и возвращает заказанные напитки из меню ивента по их id
+func (s *service) orderDrinks(ctx context.Context, dto CreateOrderDTO) (map[string]menu.Drink, error) {
+	if len(dto.Items) == 0 {
+		return nil, fmt.Errorf("order is empty")
+	}
+
+	for _, item := range dto.Items {
+		if item.Quantity == 0 {
+			return nil, fmt.Errorf("drink %s quantity must be positive", item.DrinkID)
+		}
+
+		if item.Quantity > MaxQuantity {
+			return nil, fmt.Errorf("drink %s quantity must not exceed %d", item.DrinkID, MaxQuantity)
+		}
+
+		if item.IceType != "" && !isIceType(item.IceType) {
+			return nil, fmt.Errorf("unknown ice type: %s", item.IceType)
+		}
+	}
+
+	ordrBar, err := s.repository.FindOrderBar(ctx, dto.BarID)
+	if err != nil {
+		return nil, fmt.Errorf("finding bar error: %w", err)
+	}
+
+	if ordrBar.BarStatus == barStatusClosed {
+		return nil, fmt.Errorf("bar %d is closed", dto.BarID)
+	}
+
+	if dto.TabID != "" {
+		if dto.TabSecret == "" {
+			return nil, fmt.Errorf("tab secret field is empty")
+		}
+
+		tb, err := s.tabRepos.FindTab(ctx, tab.FindTabDTO{ID: dto.TabID})
+		if err != nil {
+			return nil, fmt.Errorf("finding tab error: %v", err)
+		}
+
+		if tb.Status != tab.StatusOpen {
+			return nil, fmt.Errorf("tab %s is closed", tb.ID)
+		}
+
+		if tb.EventID != ordrBar.EventID {
+			return nil, fmt.Errorf("tab %s belongs to another event", tb.ID)
+		}
+	}
+
+	menuDrinks, err := s.menuDrinks(ctx, ordrBar.MenuID)
+	if err != nil {
+		return nil, err
+	}
+
+	drinks := make(map[string]menu.Drink, len(dto.Items))
+
+	for _, item := range dto.Items {
+		drink, ok := menuDrinks[item.DrinkID]
+		if !ok {
+			return nil, fmt.Errorf("drink %s is not in the event menu", item.DrinkID)
+		}
+
+		served := false
+		for _, barID := range drink.BarsID {
+			if barID == dto.BarID {
+				served = true
+				break
+			}
+		}
+
+		if !served {
+			return nil, fmt.Errorf("drink %s is not served at bar %d", drink.Name, dto.BarID)
+		}
+
+		for _, drinkID := range ordrBar.UnavailableDrinks {
+			if drinkID == drink.ID {
+				return nil, fmt.Errorf("drink %s is unavailable at bar %d now", drink.Name, dto.BarID)
+			}
+		}
+
+		drinks[drink.ID] = drink
+	}
+
+	return drinks, nil
+}
+
+func (s *service) menuDrinks(ctx context.Context, menuID string) (map[string]menu.Drink, error) {
+	mn, err := s.menuRepos.FindMenu(ctx, menu.FindMenuDTO{ID: menuID})
+	if err != nil {
+		return nil, fmt.Errorf("finding menu error: %v", err)
+	}
+
+	return mn.DrinksByID(), nil
+}
+
+func isIceType(iceType string) bool {
+	switch iceType {
+	case menu.BlockIce, menu.CubedIce, menu.CrackedIce, menu.NuggetIce, menu.CrushedIce, menu.NoIce:
+		return true
+	}
+	return false
+}
+
+func (s *service) FindOrder(ctx context.Context, dto FindOrderDTO) (Order, error) {
+	s.logger.Infof("find order, order_id: %s", dto.ID)
+
+	ordr, err := s.repository.FindOrder(ctx, dto)
+
+	if err != nil {
+		return Order{}, err
+	}
+
+	s.logger.Infof("order is found")
+
+	return ordr, nil
+}
+
+func (s *service) FindBarOrders(ctx context.Context, dto FindBarOrdersDTO) ([]Order, error) {
+	s.logger.Infof("find bar orders, event_id: %s, bar_id: %d", dto.EventID, dto.BarID)
+
+	orders, err := s.repository.FindBarOrders(ctx, dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("bar orders are found")
+	s.logger.Tracef("orders count: %d", len(orders))
+
+	return orders, nil
+}
+
+func (s *service) FindEventOrders(ctx context.Context, dto FindEventOrdersDTO) ([]Order, error) {
+	s.logger.Infof("find orders from all event bars, event_id: %s", dto.EventID)
+
+	orders, err := s.repository.FindEventOrders(ctx, dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Infof("all event orders are found")
+	s.logger.Tracef("orders count: %d", len(orders))
+
+	return orders, nil
+}
+
+func (s *service) ChangeStatus(ctx context.Context, dto ChangeStatusDTO) (Order, error) {
+	s.logger.Infof("changing order %s status to %s", dto.ID, dto.Status)
+
+	ordr, err := s.repository.FindOrder(ctx, FindOrderDTO{ID: dto.ID})
+	if err != nil {
+		return Order{}, fmt.Errorf("finding order error: %w", err)
+	}
+
+	change, err := ordr.SetStatus(dto.Status, dto.UserID, time.Now())
+	if err != nil {
+		return Order{}, err
+	}
+
+	// Начисление снимается только с открытого счета: доли закрытого счета уже посчитаны
+	if ordr.TabID != "" && IsRefunded(ordr.Status) {
+		tb, err := s.tabRepos.FindTab(ctx, tab.FindTabDTO{ID: ordr.TabID})
+		if err != nil {
+			return Order{}, fmt.Errorf("finding tab error: %v", err)
+		}
+
+		if tb.Status != tab.StatusOpen {
+			return Order{}, fmt.Errorf("tab %s is closed, order %s can't be %s", tb.ID, ordr.ID, ordr.Status)
+		}
+	}
+
+	seq, err := s.repository.UpdateStatus(ctx, ordr.ID, change)
+	if err != nil {
+		return Order{}, err
+	}
+
+	ordr.History[len(ordr.History)-1].Seq = seq
+
+	s.logger.Infof("order %s status changed from %s to %s by user %s", ordr.ID, change.From, change.To, change.ChangedBy)
+
+	return ordr, nil
+}
+
+// FindBarHistory возвращает записи истории заказов бара, сделанные после dto.AfterSeq,
+// в порядке их номеров. Используется, чтобы переотправить клиенту пропущенные сообщения
+func (s *service) FindBarHistory(ctx context.Context, dto FindBarHistoryDTO) ([]HistoryEvent, error) {
+	s.logger.Infof("find bar order history, event_id: %s, bar_id: %d, after seq: %d", dto.EventID, dto.BarID, dto.AfterSeq)
+
+	events, err := s.repository.FindBarHistory(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Tracef("history events count: %d", len(events))
+
+	return events, nil
+}
+
+// Заказы бара, которые находятся в незавершенном статусе дольше dto.Timeout,
+// отсортированные от самых долгих к самым новым
+func (s *service) FindStuckOrders(ctx context.Context, dto FindStuckOrdersDTO) ([]RespStuckOrder, error) {
+	s.logger.Infof("find stuck orders, event_id: %s, bar_id: %d", dto.EventID, dto.BarID)
+
+	orders, err := s.repository.FindBarOrders(ctx, FindBarOrdersDTO{BarID: dto.BarID, EventID: dto.EventID})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stuck := make([]RespStuckOrder, 0)
+
+	for _, ordr := range orders {
+		if IsFinal(ordr.Status) {
+			continue
+		}
+
+		waiting := now.Sub(ordr.StatusSince())
+		if waiting < dto.Timeout {
+			continue
+		}
+
+		stuck = append(stuck, RespStuckOrder{
+			Order:          ordr,
+			WaitingSeconds: waiting.Seconds(),
+		})
+	}
+
+	sort.Slice(stuck, func(i, j int) bool {
+		return stuck[i].WaitingSeconds > stuck[j].WaitingSeconds
+	})
+
+	s.logger.Infof("stuck orders found: %d", len(stuck))
+
+	return stuck, nil
+}
+
+// Среднее время прохождения каждого статуса заказами бара
+func (s *service) GetStageStats(ctx context.Context, dto FindBarOrdersDTO) (RespStageStats, error) {
+	s.logger.Infof("get order stage stats, event_id: %s, bar_id: %d", dto.EventID, dto.BarID)
+
+	orders, err := s.repository.FindBarOrders(ctx, dto)
+	if err != nil {
+		return RespStageStats{}, err
+	}
+
+	totals := make(map[string]time.Duration, 0)
+	counts := make(map[string]int, 0)
+
+	for _, ordr := range orders {
+		for status, d := range ordr.StageDurations() {
+			totals[status] += d
+			counts[status]++
+		}
+	}
+
+	resp := RespStageStats{
+		BarID:  dto.BarID,
+		Stages: make([]StageStat, 0, len(totals)),
+	}
+
+	for _, status := range []string{StatusPlaced, StatusAccepted, StatusPreparing, StatusReady} {
+		if counts[status] == 0 {
+			continue
+		}
+
+		resp.Stages = append(resp.Stages, StageStat{
+			Status:     status,
+			Orders:     counts[status],
+			AvgSeconds: (totals[status] / time.Duration(counts[status])).Seconds(),
+		})
+	}
+
+	return resp, nil
+}
+
+// GetThroughput считает загрузку бара по времени размещения и смены статусов заказов:
+// заказы в минуту и среднее время от размещения до подачи за последние dto.Window,
+// длину очереди и самые долгие открытые заказы
+func (s *service) GetThroughput(ctx context.Context, dto FindThroughputDTO) (RespThroughput, error) {
+	s.logger.Infof("get bar throughput, event_id: %s, bar_id: %d", dto.EventID, dto.BarID)
+
+	if dto.Window <= 0 {
+		return RespThroughput{}, fmt.Errorf("stats window must be positive")
+	}
+
+	now := time.Now()
+
+	load, err := s.repository.FindBarLoad(ctx, dto, now.Add(-dto.Window))
+	if err != nil {
+		return RespThroughput{}, err
+	}
+
+	resp := RespThroughput{
+		BarID:           dto.BarID,
+		WindowMinutes:   dto.Window.Minutes(),
+		OrdersPerMinute: float64(load.Placed) / dto.Window.Minutes(),
+		QueueLength:     load.QueueLength,
+		ServedOrders:    load.Served,
+		SlowestOrders:   make([]SlowOrder, 0, len(load.Open)),
+		ComputedAt:      now,
+	}
+
+	if load.Served > 0 {
+		resp.AvgServeSeconds = load.ServeSeconds / float64(load.Served)
+	}
+
+	for _, ordr := range load.Open {
+		resp.SlowestOrders = append(resp.SlowestOrders, SlowOrder{
+			OrderID:    ordr.ID,
+			Status:     ordr.Status,
+			AgeSeconds: now.Sub(ordr.CreatedAt).Seconds(),
+		})
+	}
+
+	return resp, nil
+}
+
+// Очередь бармена: незавершенные заказы бара в порядке поступления,
+// позиции которых расписаны с названием напитка и итоговым типом льда
+func (s *service) GetBarQueue(ctx context.Context, dto FindBarOrdersDTO) ([]QueueOrder, error) {
+	s.logger.Infof("get bar queue, event_id: %s, bar_id: %d", dto.EventID, dto.BarID)
+
+	ordrBar, err := s.repository.FindOrderBar(ctx, dto.BarID)
+	if err != nil {
+		return nil, fmt.Errorf("finding bar error: %w", err)
+	}
+
+	drinks, err := s.menuDrinks(ctx, ordrBar.MenuID)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.repository.FindBarOrders(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := make([]QueueOrder, 0)
+
+	for _, ordr := range orders {
+		if IsFinal(ordr.Status) {
+			continue
+		}
+
+		queueOrder := QueueOrder{
+			OrderID:  ordr.ID,
+			Status:   ordr.Status,
+			PlacedAt: ordr.CreatedAt,
+			Comment:  ordr.Comment,
+			Total:    ordr.Total,
+			Lines:    make([]QueueLine, 0, len(ordr.Items)),
+		}
+
+		for _, item := range ordr.Items {
+			drink := drinks[item.DrinkID]
+			name, _, _ := ItemDrink(item, drink)
+
+			iceType := item.IceType
+			if iceType == "" {
+				iceType = drink.OrderIceType
+			}
+
+			queueOrder.Lines = append(queueOrder.Lines, QueueLine{
+				DrinkID:       item.DrinkID,
+				Name:          name,
+				CookingMethod: drink.Cooking_method,
+				Quantity:      item.Quantity,
+				IceType:       iceType,
+				Note:          item.Note,
+			})
+		}
+
+		queue = append(queue, queueOrder)
+	}
+
+	s.logger.Infof("orders in queue: %d", len(queue))
+
+	return queue, nil
+}
@@ -0,0 +1,68 @@
+package order
+
+import (
+	"context"
+	"io"
+	"restapi/internal/domain/menu"
+	"restapi/pkg/logging"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Хранилища, в которых есть только то, что нужно для размещения заказа
+type testRepository struct {
+	Repository
+	created CreateOrderDTO
+}
+
+func (r *testRepository) FindOrderBar(_ context.Context, barID uint32) (OrderBar, error) {
+	return OrderBar{BarID: barID, EventID: "event", MenuID: "menu"}, nil
+}
+
+func (r *testRepository) CreateOrder(_ context.Context, dto CreateOrderDTO, total uint32) (Order, error) {
+	r.created = dto
+	return Order{ID: "order", BarID: dto.BarID, Items: dto.Items, Total: total, Status: StatusPlaced}, nil
+}
+
+type testMenuRepository struct {
+	menu.Repository
+}
+
+func (testMenuRepository) FindMenu(context.Context, menu.FindMenuDTO) (menu.Menu, error) {
+	return menu.Menu{ID: "menu", Drinks: map[string][]menu.Drink{
+		menu.Beer: {{ID: "beer", Name: "Beer", Category: menu.Beer, Price: 300, BarsID: []uint32{1}}},
+	}}, nil
+}
+
+func testLogger() *logging.Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	return &logging.Logger{Entry: logrus.NewEntry(l)}
+}
+
+func TestNewOrderKeepsCallerItems(t *testing.T) {
+	repo := &testRepository{}
+	s := NewService(repo, testMenuRepository{}, nil, testLogger())
+
+	items := []OrderItem{{DrinkID: "beer", Quantity: 2}}
+
+	ordr, err := s.NewOrder(context.Background(), CreateOrderDTO{BarID: 1, Items: items})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if items[0] != (OrderItem{DrinkID: "beer", Quantity: 2}) {
+		t.Errorf("caller items are changed: %+v", items)
+	}
+
+	want := OrderItem{DrinkID: "beer", Name: "Beer", Category: menu.Beer, Price: 300, Quantity: 2}
+	if len(repo.created.Items) != 1 || repo.created.Items[0] != want {
+		t.Errorf("stored items = %+v, want %+v", repo.created.Items, want)
+	}
+
+	if ordr.Total != 600 {
+		t.Errorf("total = %d, want 600", ordr.Total)
+	}
+}
@@ -0,0 +1,18 @@
+package order
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	CreateOrder(context.Context, CreateOrderDTO, uint32) (Order, error)
+	FindOrder(context.Context, FindOrderDTO) (Order, error)
+	FindBarOrders(context.Context, FindBarOrdersDTO) ([]Order, error)
+	FindEventOrders(context.Context, FindEventOrdersDTO) ([]Order, error)
+	EachEventOrder(context.Context, FindEventOrdersDTO, func(Order) error) error
+	UpdateStatus(context.Context, string, StatusChange) (uint64, error)
+	FindBarHistory(context.Context, FindBarHistoryDTO) ([]HistoryEvent, error)
+	FindOrderBar(context.Context, uint32) (OrderBar, error)
+	FindBarLoad(context.Context, FindThroughputDTO, time.Time) (BarLoad, error)
+}
@@ -0,0 +1,186 @@
+package report
+
+import (
+	"math"
+	"restapi/internal/domain/menu"
+	"restapi/internal/domain/order"
+	"restapi/pkg/units"
+	"sort"
+	"time"
+)
+
+// Generate собирает отчет ивента по его заказам.
+// Названия, категории и цены напитков берутся из позиций заказов, состав - из меню ивента,
+// цены ингредиентов - из закупок, часы считаются в поясе loc
+func Generate(eventID string, orders []order.Order, mn menu.Menu, purchases []Purchase,
+	loc *time.Location, at time.Time) Report {
+	drinks := mn.DrinksByID()
+
+	rep := Report{
+		EventID:     eventID,
+		GeneratedAt: at,
+	}
+
+	bars := make(map[uint32]*BarTotals)
+	drinkStats := make(map[string]*DrinkStat)
+	categories := make(map[string]*CategoryStat)
+	usage := make(map[menu.IngredientKey]float64)
+	hours := make(map[time.Time]uint32)
+
+	for _, ordr := range orders {
+		rep.TotalOrders++
+
+		bar, ok := bars[ordr.BarID]
+		if !ok {
+			bar = &BarTotals{BarID: ordr.BarID}
+			bars[ordr.BarID] = bar
+		}
+		bar.TotalOrders++
+
+		created := ordr.CreatedAt.In(loc)
+		hours[time.Date(created.Year(), created.Month(), created.Day(), created.Hour(), 0, 0, 0, loc)]++
+
+		switch ordr.Status {
+		case order.StatusCancelled, order.StatusRejected:
+			rep.CancelledOrders++
+		case order.StatusServed:
+			rep.ServedOrders++
+			rep.Revenue += ordr.Total
+			bar.ServedOrders++
+			bar.Revenue += ordr.Total
+
+			for _, item := range ordr.Items {
+				drink := drinks[item.DrinkID]
+				name, category, price := order.ItemDrink(item, drink)
+				revenue := price * item.Quantity
+
+				stat, ok := drinkStats[item.DrinkID]
+				if !ok {
+					stat = &DrinkStat{DrinkID: item.DrinkID, Name: name, Category: category}
+					drinkStats[item.DrinkID] = stat
+				}
+				stat.Quantity += item.Quantity
+				stat.Revenue += revenue
+
+				cat, ok := categories[category]
+				if !ok {
+					cat = &CategoryStat{Category: category}
+					categories[category] = cat
+				}
+				cat.Quantity += item.Quantity
+				cat.Revenue += revenue
+
+				addUsage(usage, drink, item)
+			}
+		}
+	}
+
+	rep.Bars = make([]BarTotals, 0, len(bars))
+	for _, bar := range bars {
+		rep.Bars = append(rep.Bars, *bar)
+	}
+	sort.Slice(rep.Bars, func(i, j int) bool { return rep.Bars[i].BarID < rep.Bars[j].BarID })
+
+	rep.TopDrinks = make([]DrinkStat, 0, len(drinkStats))
+	for _, stat := range drinkStats {
+		rep.TopDrinks = append(rep.TopDrinks, *stat)
+	}
+	sort.Slice(rep.TopDrinks, func(i, j int) bool {
+		if rep.TopDrinks[i].Quantity != rep.TopDrinks[j].Quantity {
+			return rep.TopDrinks[i].Quantity > rep.TopDrinks[j].Quantity
+		}
+		return rep.TopDrinks[i].Name < rep.TopDrinks[j].Name
+	})
+
+	rep.TopCategories = make([]CategoryStat, 0, len(categories))
+	for _, cat := range categories {
+		rep.TopCategories = append(rep.TopCategories, *cat)
+	}
+	sort.Slice(rep.TopCategories, func(i, j int) bool {
+		if rep.TopCategories[i].Quantity != rep.TopCategories[j].Quantity {
+			return rep.TopCategories[i].Quantity > rep.TopCategories[j].Quantity
+		}
+		return rep.TopCategories[i].Category < rep.TopCategories[j].Category
+	})
+
+	prices := unitPrices(purchases)
+
+	rep.Ingredients = make([]IngredientUsage, 0, len(usage))
+	for key, amount := range usage {
+		price, ok := prices[key]
+		if !ok {
+			rep.UnpricedIngredients++
+		}
+
+		cost := uint32(math.Round(amount * price))
+		rep.IngredientsCost += cost
+
+		rep.Ingredients = append(rep.Ingredients, IngredientUsage{
+			Type:     key.Type,
+			Name:     key.Name,
+			Unit:     key.Unit,
+			Amount:   uint32(math.Round(amount)),
+			Cost:     cost,
+			Unpriced: !ok,
+		})
+	}
+	sort.Slice(rep.Ingredients, func(i, j int) bool {
+		if rep.Ingredients[i].Type != rep.Ingredients[j].Type {
+			return rep.Ingredients[i].Type < rep.Ingredients[j].Type
+		}
+		if rep.Ingredients[i].Name != rep.Ingredients[j].Name {
+			return rep.Ingredients[i].Name < rep.Ingredients[j].Name
+		}
+		return rep.Ingredients[i].Unit < rep.Ingredients[j].Unit
+	})
+
+	// Сначала самые загруженные часы
+	rep.PeakHours = make([]HourStat, 0, len(hours))
+	for hour, count := range hours {
+		rep.PeakHours = append(rep.PeakHours, HourStat{Hour: hour, Orders: count})
+	}
+	sort.Slice(rep.PeakHours, func(i, j int) bool {
+		if rep.PeakHours[i].Orders != rep.PeakHours[j].Orders {
+			return rep.PeakHours[i].Orders > rep.PeakHours[j].Orders
+		}
+		return rep.PeakHours[i].Hour.Before(rep.PeakHours[j].Hour)
+	})
+
+	return rep
+}
+
+// addUsage добавляет расход ингредиентов позиции заказа в базовых единицах.
+// Лед считается в граммах по типу из заказа, а если он не указан - по типу из рецепта
+func addUsage(usage map[menu.IngredientKey]float64, drink menu.Drink, item order.OrderItem) {
+	ice := drink.OrderIceType
+	if item.IceType != "" {
+		ice = item.IceType
+	}
+
+	drink.AddIngredients(usage, float64(item.Quantity), ice)
+}
+
+// unitPrices считает цену базовой единицы каждого закупленного ингредиента.
+// Если ингредиент закупался несколько раз, цена средняя
+func unitPrices(purchases []Purchase) map[menu.IngredientKey]float64 {
+	costs := make(map[menu.IngredientKey]float64)
+	amounts := make(map[menu.IngredientKey]float64)
+
+	for _, p := range purchases {
+		amount, base, err := units.Normalize(float64(p.Volume), p.Unit)
+		if err != nil || amount == 0 {
+			continue
+		}
+
+		key := menu.IngredientKey{Type: p.Type, Name: p.Name, Unit: string(base)}
+		costs[key] += float64(p.Cost)
+		amounts[key] += amount
+	}
+
+	prices := make(map[menu.IngredientKey]float64, len(costs))
+	for key, cost := range costs {
+		prices[key] = cost / amounts[key]
+	}
+
+	return prices
+}
@@ -0,0 +1,226 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"restapi/internal/domain/menu"
+	"restapi/internal/domain/order"
+)
+
+func testMenu() menu.Menu {
+	return menu.Menu{
+		ID: "menu",
+		Drinks: map[string][]menu.Drink{
+			menu.LongDrink: {{
+				ID:       "mojito",
+				Name:     "Mojito",
+				Category: menu.LongDrink,
+				Composition: menu.Composition{
+					IceBulk:    200,
+					Liquids:    []menu.Liquid{{Name: "rum", Unit: "ml", Volume: 50}},
+					SolidsBulk: []menu.SolidBulk{{Name: "sugar", Unit: "g", Volume: 10}},
+					SolidsUnit: []menu.SolidUnit{{Name: "lime", Volume: 1}},
+				},
+				OrderIceType: menu.CubedIce,
+				Price:        500,
+			}},
+			menu.Beer: {{
+				ID:       "beer",
+				Name:     "Beer",
+				Category: menu.Beer,
+				Composition: menu.Composition{
+					Liquids: []menu.Liquid{{Name: "beer", Unit: "ml", Volume: 500}},
+				},
+				Price: 300,
+			}},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC)
+	placed := func(hour, min int) time.Time {
+		return time.Date(2024, 7, 1, hour, min, 0, 0, time.UTC)
+	}
+
+	orders := []order.Order{
+		// Цена в меню изменилась после заказа: выручка считается по цене заказа
+		{BarID: 1, Status: order.StatusServed, Total: 900, CreatedAt: placed(18, 10), Items: []order.OrderItem{
+			{DrinkID: "mojito", Name: "Mojito", Category: menu.LongDrink, Price: 450, Quantity: 2},
+		}},
+		{BarID: 2, Status: order.StatusServed, Total: 300, CreatedAt: placed(18, 40), Items: []order.OrderItem{
+			{DrinkID: "beer", Name: "Beer", Category: menu.Beer, Price: 300, Quantity: 1},
+		}},
+		{BarID: 1, Status: order.StatusCancelled, Total: 450, CreatedAt: placed(19, 5), Items: []order.OrderItem{
+			{DrinkID: "mojito", Name: "Mojito", Category: menu.LongDrink, Price: 450, Quantity: 1},
+		}},
+		// Заказ, сделанный до того, как позиции стали запоминать напиток
+		{BarID: 2, Status: order.StatusServed, Total: 600, CreatedAt: placed(19, 20), Items: []order.OrderItem{
+			{DrinkID: "beer", Quantity: 2},
+		}},
+		// Напиток удален из меню после заказа
+		{BarID: 1, Status: order.StatusServed, Total: 700, CreatedAt: placed(19, 50), Items: []order.OrderItem{
+			{DrinkID: "old", Name: "Old fashioned", Category: menu.ShortDrink, Price: 700, Quantity: 1},
+		}},
+	}
+
+	purchases := []Purchase{
+		{Type: menu.LiquidType, Name: "rum", Unit: "l", Volume: 1, Cost: 2000},
+		{Type: menu.LiquidType, Name: "beer", Unit: "l", Volume: 1, Cost: 400},
+		{Type: menu.SolidBulkType, Name: "sugar", Unit: "kg", Volume: 1, Cost: 100},
+		{Type: menu.SolidUnitType, Name: "lime", Unit: "pcs", Volume: 10, Cost: 300},
+		{Type: menu.IceType, Name: menu.CubedIce, Unit: "kg", Volume: 1, Cost: 50},
+	}
+
+	tests := []struct {
+		name   string
+		orders []order.Order
+		want   Report
+	}{
+		{
+			name:   "no orders",
+			orders: nil,
+			want: Report{
+				EventID:       "event",
+				Bars:          []BarTotals{},
+				TopDrinks:     []DrinkStat{},
+				TopCategories: []CategoryStat{},
+				Ingredients:   []IngredientUsage{},
+				PeakHours:     []HourStat{},
+				GeneratedAt:   at,
+			},
+		},
+		{
+			name:   "served and cancelled orders",
+			orders: orders,
+			want: Report{
+				EventID:         "event",
+				TotalOrders:     5,
+				ServedOrders:    4,
+				CancelledOrders: 1,
+				Revenue:         2500,
+				IngredientsCost: 882,
+				Bars: []BarTotals{
+					{BarID: 1, TotalOrders: 3, ServedOrders: 2, Revenue: 1600},
+					{BarID: 2, TotalOrders: 2, ServedOrders: 2, Revenue: 900},
+				},
+				TopDrinks: []DrinkStat{
+					{DrinkID: "beer", Name: "Beer", Category: menu.Beer, Quantity: 3, Revenue: 900},
+					{DrinkID: "mojito", Name: "Mojito", Category: menu.LongDrink, Quantity: 2, Revenue: 900},
+					{DrinkID: "old", Name: "Old fashioned", Category: menu.ShortDrink, Quantity: 1, Revenue: 700},
+				},
+				TopCategories: []CategoryStat{
+					{Category: menu.Beer, Quantity: 3, Revenue: 900},
+					{Category: menu.LongDrink, Quantity: 2, Revenue: 900},
+					{Category: menu.ShortDrink, Quantity: 1, Revenue: 700},
+				},
+				Ingredients: []IngredientUsage{
+					{Type: menu.IceType, Name: menu.CubedIce, Unit: "g", Amount: 400, Cost: 20},
+					{Type: menu.LiquidType, Name: "beer", Unit: "ml", Amount: 1500, Cost: 600},
+					{Type: menu.LiquidType, Name: "rum", Unit: "ml", Amount: 100, Cost: 200},
+					{Type: menu.SolidBulkType, Name: "sugar", Unit: "g", Amount: 20, Cost: 2},
+					{Type: menu.SolidUnitType, Name: "lime", Unit: "pcs", Amount: 2, Cost: 60},
+				},
+				PeakHours: []HourStat{
+					{Hour: time.Date(2024, 7, 1, 22, 0, 0, 0, loc), Orders: 3},
+					{Hour: time.Date(2024, 7, 1, 21, 0, 0, 0, loc), Orders: 2},
+				},
+				GeneratedAt: at,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Generate("event", tt.orders, testMenu(), purchases, loc, at)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Generate() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateUnpriced(t *testing.T) {
+	at := time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC)
+	orders := []order.Order{
+		{BarID: 1, Status: order.StatusServed, Total: 500, CreatedAt: at, Items: []order.OrderItem{
+			{DrinkID: "mojito", Name: "Mojito", Category: menu.LongDrink, Price: 500, Quantity: 1},
+		}},
+	}
+	liquids := []Purchase{
+		{Type: menu.LiquidType, Name: "rum", Unit: "l", Volume: 1, Cost: 2000},
+		{Type: menu.SolidUnitType, Name: "lime", Unit: "pcs", Volume: 10, Cost: 300},
+	}
+
+	tests := []struct {
+		name      string
+		purchases []Purchase
+		wantIce   IngredientUsage
+		wantSugar IngredientUsage
+		wantCost  uint32
+		unpriced  uint32
+	}{
+		{
+			name: "purchased in the usage dimension",
+			purchases: append([]Purchase{
+				{Type: menu.IceType, Name: menu.CubedIce, Unit: "kg", Volume: 2, Cost: 100},
+				{Type: menu.SolidBulkType, Name: "sugar", Unit: "g", Volume: 500, Cost: 50},
+			}, liquids...),
+			wantIce:   IngredientUsage{Type: menu.IceType, Name: menu.CubedIce, Unit: "g", Amount: 200, Cost: 10},
+			wantSugar: IngredientUsage{Type: menu.SolidBulkType, Name: "sugar", Unit: "g", Amount: 10, Cost: 1},
+			wantCost:  141,
+		},
+		{
+			name: "purchased in another dimension",
+			purchases: append([]Purchase{
+				{Type: menu.IceType, Name: menu.CubedIce, Unit: "l", Volume: 2, Cost: 100},
+				{Type: menu.SolidBulkType, Name: "sugar", Unit: "ml", Volume: 500, Cost: 50},
+			}, liquids...),
+			wantIce:   IngredientUsage{Type: menu.IceType, Name: menu.CubedIce, Unit: "g", Amount: 200, Unpriced: true},
+			wantSugar: IngredientUsage{Type: menu.SolidBulkType, Name: "sugar", Unit: "g", Amount: 10, Unpriced: true},
+			wantCost:  130,
+			unpriced:  2,
+		},
+		{
+			name:      "not purchased",
+			purchases: liquids,
+			wantIce:   IngredientUsage{Type: menu.IceType, Name: menu.CubedIce, Unit: "g", Amount: 200, Unpriced: true},
+			wantSugar: IngredientUsage{Type: menu.SolidBulkType, Name: "sugar", Unit: "g", Amount: 10, Unpriced: true},
+			wantCost:  130,
+			unpriced:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Generate("event", orders, testMenu(), tt.purchases, time.UTC, at)
+
+			want := []IngredientUsage{
+				tt.wantIce,
+				{Type: menu.LiquidType, Name: "rum", Unit: "ml", Amount: 50, Cost: 100},
+				tt.wantSugar,
+				{Type: menu.SolidUnitType, Name: "lime", Unit: "pcs", Amount: 1, Cost: 30},
+			}
+
+			if !reflect.DeepEqual(got.Ingredients, want) {
+				t.Errorf("Ingredients =\n%+v\nwant\n%+v", got.Ingredients, want)
+			}
+
+			if got.IngredientsCost != tt.wantCost {
+				t.Errorf("IngredientsCost = %d, want %d", got.IngredientsCost, tt.wantCost)
+			}
+
+			if got.UnpricedIngredients != tt.unpriced {
+				t.Errorf("UnpricedIngredients = %d, want %d", got.UnpricedIngredients, tt.unpriced)
+			}
+		})
+	}
+}
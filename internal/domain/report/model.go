@@ -1,4 +1,71 @@
 package report
 
+import "time"
+
+// Отчет по ивенту, собирается при его завершении.
+// Выручка и расход ингредиентов считаются только по поданным заказам
 type Report struct {
+	EventID         string            `json:"event_id"`
+	TotalOrders     uint32            `json:"total_orders"`
+	ServedOrders    uint32            `json:"served_orders"`
+	CancelledOrders uint32            `json:"cancelled_orders"`
+	Revenue         uint32            `json:"revenue"`
+	IngredientsCost uint32            `json:"ingredients_cost"`
+	Bars            []BarTotals       `json:"bars"`
+	TopDrinks       []DrinkStat       `json:"top_drinks"`
+	TopCategories   []CategoryStat    `json:"top_categories"`
+	Ingredients     []IngredientUsage `json:"ingredients"`
+	// Сколько позиций Ingredients не вошло в IngredientsCost, потому что их цена неизвестна
+	UnpricedIngredients uint32     `json:"unpriced_ingredients,omitempty"`
+	PeakHours           []HourStat `json:"peak_hours"`
+	GeneratedAt         time.Time  `json:"generated_at"`
+}
+
+type BarTotals struct {
+	BarID        uint32 `json:"bar_id"`
+	TotalOrders  uint32 `json:"total_orders"`
+	ServedOrders uint32 `json:"served_orders"`
+	Revenue      uint32 `json:"revenue"`
+}
+
+type DrinkStat struct {
+	DrinkID  string `json:"drink_id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Quantity uint32 `json:"quantity"`
+	Revenue  uint32 `json:"revenue"`
+}
+
+type CategoryStat struct {
+	Category string `json:"category"`
+	Quantity uint32 `json:"quantity"`
+	Revenue  uint32 `json:"revenue"`
+}
+
+// Расход ингредиента по составу поданных напитков в базовой единице units (ml, g, pcs).
+// У льда Name содержит ice_type. Cost - стоимость расхода по закупочной цене ингредиента ивента.
+// Unpriced - ингредиент не закупался в этой величине (например, сахар в рецепте в ml, а закуплен в kg),
+// тогда Cost равен 0 и не означает, что ингредиент бесплатный
+type IngredientUsage struct {
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Unit     string `json:"unit,omitempty"`
+	Amount   uint32 `json:"amount"`
+	Cost     uint32 `json:"cost"`
+	Unpriced bool   `json:"unpriced,omitempty"`
+}
+
+// Закупленный на ивент ингредиент: Cost заплачено за Volume в единицах Unit
+type Purchase struct {
+	Type   string
+	Name   string
+	Unit   string
+	Volume uint32
+	Cost   uint32
+}
+
+// Количество заказов, размещенных за час, который начинается в Hour (по времени ивента)
+type HourStat struct {
+	Hour   time.Time `json:"hour"`
+	Orders uint32    `json:"orders"`
 }
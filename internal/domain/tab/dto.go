@@ -0,0 +1,44 @@
+package tab
+
+type OpenTabDTO struct {
+	EventID   string `json:"event_id"`
+	GuestName string `json:"guest_name"`
+}
+
+// Гость получает счет только по его секрету. Пустой Secret хранилище не проверяет,
+// так ищут счет другие сервисы
+type FindTabDTO struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret,omitempty"`
+}
+
+type FindEventTabsDTO struct {
+	EventID string `json:"event_id"`
+}
+
+type CloseTabDTO struct {
+	ID string `json:"id"`
+}
+
+// Если Shares пустой, счет делится поровну между Guests
+type SplitTabDTO struct {
+	ID     string   `json:"id"`
+	Guests []string `json:"guests,omitempty"`
+	Shares []Share  `json:"shares,omitempty"`
+}
+
+type RespEventTabs struct {
+	Tabs []Tab `json:"tabs"`
+}
+
+// Итог вечера: кто сколько должен
+type RespEventBill struct {
+	EventID string      `json:"event_id"`
+	Total   uint32      `json:"total"`
+	Guests  []GuestBill `json:"guests"`
+}
+
+type GuestBill struct {
+	GuestName string `json:"guest_name"`
+	Amount    uint32 `json:"amount"`
+}
@@ -0,0 +1,109 @@
+package tab
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// tab statuses
+	StatusOpen   = "open"
+	StatusClosed = "closed"
+)
+
+// Счет гостя на ивенте. На открытый счет начисляется стоимость заказов гостя,
+// закрытый счет можно разделить между несколькими гостями.
+// Secret возвращается только при открытии счета: заказ начисляется на счет
+// только вместе с ним, поэтому чужой счет по одному id не оплатить
+type Tab struct {
+	ID        string     `json:"id"`
+	Secret    string     `json:"secret,omitempty"`
+	EventID   string     `json:"event_id"`
+	GuestName string     `json:"guest_name"`
+	Status    string     `json:"status"`
+	Total     uint32     `json:"total"`
+	Charges   []Charge   `json:"charges"`
+	Shares    []Share    `json:"shares,omitempty"`
+	OpenedAt  time.Time  `json:"opened_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+}
+
+// Начисление стоимости заказа на счет
+type Charge struct {
+	OrderID   string    `json:"order_id"`
+	Amount    uint32    `json:"amount"`
+	ChargedAt time.Time `json:"charged_at"`
+}
+
+// Доля гостя в разделенном счете
+type Share struct {
+	GuestName string `json:"guest_name"`
+	Amount    uint32 `json:"amount"`
+}
+
+// CountTotal считает сумму всех начислений счета
+func (t Tab) CountTotal() uint32 {
+	var total uint32
+	for _, charge := range t.Charges {
+		total += charge.Amount
+	}
+	return total
+}
+
+// SplitEqually делит сумму счета поровну между гостями.
+// Остаток от деления распределяется по одной единице на первых гостей
+func SplitEqually(total uint32, guests []string) ([]Share, error) {
+	if len(guests) == 0 {
+		return nil, fmt.Errorf("guests list is empty")
+	}
+
+	n := uint32(len(guests))
+	shares := make([]Share, 0, n)
+
+	for i, guest := range guests {
+		amount := total / n
+		if uint32(i) < total%n {
+			amount++
+		}
+
+		shares = append(shares, Share{
+			GuestName: guest,
+			Amount:    amount,
+		})
+	}
+
+	return shares, nil
+}
+
+// ValidateShares проверяет, что у каждой доли есть гость, а доли в сумме дают total
+func ValidateShares(shares []Share, total uint32) error {
+	var sum uint64
+	for _, share := range shares {
+		if share.GuestName == "" {
+			return fmt.Errorf("guest name field is empty")
+		}
+		sum += uint64(share.Amount)
+	}
+
+	if sum != uint64(total) {
+		return fmt.Errorf("shares sum %d doesn't match tab total %d", sum, total)
+	}
+
+	return nil
+}
+
+// Debts возвращает, сколько должен каждый гость по этому счету
+func (t Tab) Debts() map[string]uint32 {
+	debts := make(map[string]uint32, 0)
+
+	if len(t.Shares) == 0 {
+		debts[t.GuestName] = t.Total
+		return debts
+	}
+
+	for _, share := range t.Shares {
+		debts[share.GuestName] += share.Amount
+	}
+
+	return debts
+}
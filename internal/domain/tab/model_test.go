@@ -0,0 +1,118 @@
+package tab
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitEqually(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   uint32
+		guests  []string
+		want    []Share
+		wantErr bool
+	}{
+		{"no guests", 100, nil, nil, true},
+		{"one guest", 100, []string{"Ann"}, []Share{{"Ann", 100}}, false},
+		{"even split", 900, []string{"Ann", "Bob", "Eve"}, []Share{{"Ann", 300}, {"Bob", 300}, {"Eve", 300}}, false},
+		{"remainder goes to the first guests", 1001, []string{"Ann", "Bob", "Eve"},
+			[]Share{{"Ann", 334}, {"Bob", 334}, {"Eve", 333}}, false},
+		{"total less than guests", 2, []string{"Ann", "Bob", "Eve"}, []Share{{"Ann", 1}, {"Bob", 1}, {"Eve", 0}}, false},
+		{"empty tab", 0, []string{"Ann", "Bob"}, []Share{{"Ann", 0}, {"Bob", 0}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitEqually(tt.total, tt.guests)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SplitEqually() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitEqually() = %+v, want %+v", got, tt.want)
+			}
+
+			if !tt.wantErr {
+				if err := ValidateShares(got, tt.total); err != nil {
+					t.Errorf("shares don't add up to the total: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateShares(t *testing.T) {
+	tests := []struct {
+		name    string
+		shares  []Share
+		total   uint32
+		wantErr bool
+	}{
+		{"sum matches", []Share{{"Ann", 400}, {"Bob", 600}}, 1000, false},
+		{"sum is less", []Share{{"Ann", 400}, {"Bob", 500}}, 1000, true},
+		{"sum is more", []Share{{"Ann", 400}, {"Bob", 700}}, 1000, true},
+		{"no shares for an empty tab", nil, 0, false},
+		{"no shares for a tab with charges", nil, 100, true},
+		{"guest without a name", []Share{{"Ann", 400}, {"", 600}}, 1000, true},
+		{"sum overflows uint32", []Share{{"Ann", 1 << 31}, {"Bob", 1 << 31}}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateShares(tt.shares, tt.total)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateShares() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDebts(t *testing.T) {
+	tests := []struct {
+		name string
+		tab  Tab
+		want map[string]uint32
+	}{
+		{
+			name: "not split tab is owed by its guest",
+			tab:  Tab{GuestName: "Ann", Total: 1000},
+			want: map[string]uint32{"Ann": 1000},
+		},
+		{
+			name: "split tab is owed by shares",
+			tab:  Tab{GuestName: "Ann", Total: 1000, Shares: []Share{{"Bob", 600}, {"Eve", 400}}},
+			want: map[string]uint32{"Bob": 600, "Eve": 400},
+		},
+		{
+			name: "shares of one guest are summed",
+			tab:  Tab{GuestName: "Ann", Total: 1000, Shares: []Share{{"Bob", 600}, {"Bob", 100}, {"Ann", 300}}},
+			want: map[string]uint32{"Ann": 300, "Bob": 700},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tab.Debts(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Debts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountTotal(t *testing.T) {
+	tests := []struct {
+		name    string
+		charges []Charge
+		want    uint32
+	}{
+		{"no charges", nil, 0},
+		{"several charges", []Charge{{OrderID: "1", Amount: 300}, {OrderID: "2", Amount: 450}}, 750},
+	}
+
+	for _, tt := range tests {
+		if got := (Tab{Charges: tt.charges}).CountTotal(); got != tt.want {
+			t.Errorf("%s: CountTotal() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package tab
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"restapi/pkg/logging"
+	"sort"
+	"time"
+)
+
+type Service interface {
+	OpenTab(context.Context, OpenTabDTO) (Tab, error)
+	FindTab(context.Context, FindTabDTO) (Tab, error)
+	FindEventTabs(context.Context, FindEventTabsDTO) (RespEventTabs, error)
+	CloseTab(context.Context, CloseTabDTO) error
+	SplitTab(context.Context, SplitTabDTO) (Tab, error)
+	GetEventBill(context.Context, FindEventTabsDTO) (RespEventBill, error)
+}
+
+type service struct {
+	repository Repository
+	logger     *logging.Logger
+}
+
+func NewService(repository Repository, logger *logging.Logger) Service {
+	return &service{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (s *service) OpenTab(ctx context.Context, dto OpenTabDTO) (Tab, error) {
+	s.logger.Infof("opening tab for guest %s", dto.GuestName)
+
+	if dto.EventID == "" {
+		return Tab{}, fmt.Errorf("event id field is empty")
+	}
+
+	if dto.GuestName == "" {
+		return Tab{}, fmt.Errorf("guest name field is empty")
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return Tab{}, fmt.Errorf("generating tab secret error: %v", err)
+	}
+
+	tb, err := s.repository.CreateTab(ctx, dto, secret)
+	if err != nil {
+		return Tab{}, err
+	}
+
+	s.logger.Infof("tab is opened, tab_id: %s", tb.ID)
+
+	return tb, nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", b), nil
+}
+
+// FindTab возвращает счет гостю, который знает его секрет
+func (s *service) FindTab(ctx context.Context, dto FindTabDTO) (Tab, error) {
+	s.logger.Infof("find tab, tab_id: %s", dto.ID)
+
+	if dto.Secret == "" {
+		return Tab{}, fmt.Errorf("tab secret field is empty")
+	}
+
+	tb, err := s.repository.FindTab(ctx, dto)
+	if err != nil {
+		return Tab{}, err
+	}
+
+	s.logger.Infof("tab is found")
+
+	return tb, nil
+}
+
+func (s *service) FindEventTabs(ctx context.Context, dto FindEventTabsDTO) (RespEventTabs, error) {
+	s.logger.Infof("find event tabs, event_id: %s", dto.EventID)
+
+	tabs, err := s.repository.FindEventTabs(ctx, dto)
+	if err != nil {
+		return RespEventTabs{}, err
+	}
+
+	s.logger.Infof("event tabs are found")
+
+	return RespEventTabs{Tabs: tabs}, nil
+}
+
+func (s *service) CloseTab(ctx context.Context, dto CloseTabDTO) error {
+	s.logger.Infof("closing tab %s", dto.ID)
+
+	total, err := s.repository.CloseTab(ctx, dto.ID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.logger.Infof("tab %s is closed, total: %d", dto.ID, total)
+
+	return nil
+}
+
+// SplitTab закрывает счет, если он еще открыт, и делит его сумму между гостями:
+// либо по указанным долям, сумма которых должна совпадать со счетом, либо поровну.
+// Счет закрывается до проверки долей, чтобы делилась итоговая сумма:
+// заказ, начисленный между чтением счета и закрытием, тоже в нее входит
+func (s *service) SplitTab(ctx context.Context, dto SplitTabDTO) (Tab, error) {
+	s.logger.Infof("splitting tab %s", dto.ID)
+
+	tb, err := s.repository.FindTab(ctx, FindTabDTO{ID: dto.ID})
+	if err != nil {
+		return Tab{}, fmt.Errorf("finding tab error: %w", err)
+	}
+
+	total := tb.Total
+
+	if tb.Status == StatusOpen {
+		total, err = s.repository.CloseTab(ctx, tb.ID, time.Now())
+		if err != nil {
+			return Tab{}, err
+		}
+	}
+
+	shares := dto.Shares
+
+	if len(shares) == 0 {
+		shares, err = SplitEqually(total, dto.Guests)
+	} else {
+		err = ValidateShares(shares, total)
+	}
+
+	if err != nil {
+		return Tab{}, err
+	}
+
+	err = s.repository.SetShares(ctx, tb.ID, shares)
+	if err != nil {
+		return Tab{}, err
+	}
+
+	tb, err = s.repository.FindTab(ctx, FindTabDTO{ID: tb.ID})
+	if err != nil {
+		return Tab{}, fmt.Errorf("finding tab error: %w", err)
+	}
+
+	s.logger.Infof("tab %s is split between %d guests", tb.ID, len(shares))
+
+	return tb, nil
+}
+
+// GetEventBill собирает по всем счетам ивента, сколько должен каждый гость
+func (s *service) GetEventBill(ctx context.Context, dto FindEventTabsDTO) (RespEventBill, error) {
+	s.logger.Infof("get event bill, event_id: %s", dto.EventID)
+
+	tabs, err := s.repository.FindEventTabs(ctx, dto)
+	if err != nil {
+		return RespEventBill{}, err
+	}
+
+	debts := make(map[string]uint32, 0)
+	resp := RespEventBill{
+		EventID: dto.EventID,
+	}
+
+	for _, tb := range tabs {
+		resp.Total += tb.Total
+
+		for guest, amount := range tb.Debts() {
+			debts[guest] += amount
+		}
+	}
+
+	resp.Guests = make([]GuestBill, 0, len(debts))
+	for guest, amount := range debts {
+		resp.Guests = append(resp.Guests, GuestBill{
+			GuestName: guest,
+			Amount:    amount,
+		})
+	}
+
+	sort.Slice(resp.Guests, func(i, j int) bool {
+		return resp.Guests[i].Amount > resp.Guests[j].Amount
+	})
+
+	s.logger.Infof("event bill is ready, total: %d", resp.Total)
+
+	return resp, nil
+}
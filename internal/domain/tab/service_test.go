@@ -0,0 +1,105 @@
+package tab
+
+import (
+	"context"
+	"io"
+	"restapi/pkg/logging"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Счет, на который между чтением и закрытием начислили еще один заказ
+type testRepository struct {
+	Repository
+	tab    Tab
+	closed uint32
+	shares []Share
+}
+
+func (r *testRepository) FindTab(context.Context, FindTabDTO) (Tab, error) {
+	tb := r.tab
+	tb.Shares = r.shares
+	return tb, nil
+}
+
+func (r *testRepository) CloseTab(context.Context, string, time.Time) (uint32, error) {
+	r.tab.Status = StatusClosed
+	r.tab.Total = r.closed
+	return r.closed, nil
+}
+
+func (r *testRepository) SetShares(_ context.Context, _ string, shares []Share) error {
+	r.shares = shares
+	return nil
+}
+
+func testLogger() *logging.Logger {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+
+	return &logging.Logger{Entry: logrus.NewEntry(l)}
+}
+
+func TestSplitTabUsesClosedTotal(t *testing.T) {
+	tests := []struct {
+		name    string
+		dto     SplitTabDTO
+		want    []Share
+		wantErr bool
+	}{
+		{
+			name: "equal split of the final total",
+			dto:  SplitTabDTO{ID: "tab", Guests: []string{"Ann", "Bob"}},
+			want: []Share{{"Ann", 500}, {"Bob", 500}},
+		},
+		{
+			name: "shares of the final total",
+			dto:  SplitTabDTO{ID: "tab", Shares: []Share{{"Ann", 700}, {"Bob", 300}}},
+			want: []Share{{"Ann", 700}, {"Bob", 300}},
+		},
+		{
+			name:    "shares of the stale total",
+			dto:     SplitTabDTO{ID: "tab", Shares: []Share{{"Ann", 600}, {"Bob", 300}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &testRepository{
+				tab:    Tab{ID: "tab", GuestName: "Ann", Status: StatusOpen, Total: 900},
+				closed: 1000,
+			}
+			s := NewService(repo, testLogger())
+
+			tb, err := s.SplitTab(context.Background(), tt.dto)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SplitTab() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if repo.tab.Status != StatusClosed {
+				t.Errorf("tab is not closed")
+			}
+
+			if tt.wantErr {
+				if repo.shares != nil {
+					t.Errorf("shares are saved: %+v", repo.shares)
+				}
+				return
+			}
+
+			if tb.Total != 1000 || len(tb.Shares) != len(tt.want) {
+				t.Fatalf("SplitTab() = %+v", tb)
+			}
+
+			for i := range tt.want {
+				if tb.Shares[i] != tt.want[i] {
+					t.Errorf("SplitTab() shares = %+v, want %+v", tb.Shares, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package tab
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	CreateTab(context.Context, OpenTabDTO, string) (Tab, error)
+	FindTab(context.Context, FindTabDTO) (Tab, error)
+	FindEventTabs(context.Context, FindEventTabsDTO) ([]Tab, error)
+	CloseTab(context.Context, string, time.Time) (uint32, error)
+	SetShares(context.Context, string, []Share) error
+}
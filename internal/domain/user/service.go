@@ -19,6 +19,7 @@ type Service interface {
 	SignUp(ctx context.Context, dto CreateUserDTO) (User, error)
 	SignIn(ctx context.Context, login, password string) (Tokens, error)
 	Verify(ctx context.Context, code string) error
+	GetUserIDByToken(ctx context.Context, code string) (string, error)
 	UserRefresh(ctx context.Context, dto RefreshUserDTO) (Tokens, error)
 	UpdateUser(ctx context.Context, dto UpdateUserDTO) error
 	PartUpdateUser(ctx context.Context, dto PartUpdateUserDTO) error
@@ -99,6 +100,11 @@ func (s *service) Verify(ctx context.Context, code string) error {
 	return nil
 }
 
+// Возвращает id пользователя, которому выдан access токен
+func (s *service) GetUserIDByToken(ctx context.Context, code string) (string, error) {
+	return s.tokenManager.Parse(code)
+}
+
 func (s *service) UserRefresh(ctx context.Context, dto RefreshUserDTO) (Tokens, error) {
 	s.logger.Infof("refreshing user")
 
@@ -0,0 +1,3 @@
+ALTER TABLE bars DROP COLUMN IF EXISTS last_seq;
+DROP TABLE IF EXISTS order_status_history;
+DROP TABLE IF EXISTS orders;
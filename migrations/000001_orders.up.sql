@@ -0,0 +1,32 @@
+-- Заказы раньше хранились массивом id в bars.orders, теперь это отдельная таблица.
+-- Каждая смена статуса заказа попадает в историю с номером события бара seq,
+-- по которому клиенты досылают пропущенные события после переподключения
+CREATE TABLE IF NOT EXISTS orders (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	event_id uuid NOT NULL REFERENCES events (id),
+	bar_id integer NOT NULL REFERENCES bars (id),
+	tab_id uuid,
+	items jsonb NOT NULL DEFAULT '[]',
+	comment text NOT NULL DEFAULT '',
+	total bigint NOT NULL DEFAULT 0,
+	status text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS orders_bar_event_idx ON orders (bar_id, event_id, created_at);
+CREATE INDEX IF NOT EXISTS orders_event_idx ON orders (event_id, created_at);
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+	order_id uuid NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+	from_status text NOT NULL,
+	to_status text NOT NULL,
+	changed_by text NOT NULL DEFAULT '',
+	changed_at timestamptz NOT NULL DEFAULT now(),
+	seq bigint NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_status_history_order_idx ON order_status_history (order_id, seq);
+
+-- Последний номер события бара, см. order_status_history.seq
+ALTER TABLE bars ADD COLUMN IF NOT EXISTS last_seq bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_tab_id_fkey;
+DROP TABLE IF EXISTS tab_charges;
+DROP TABLE IF EXISTS tabs;
@@ -0,0 +1,29 @@
+-- Счета гостей ивента. Заказ, сделанный на счет, начисляется в tab_charges,
+-- отмена заказа, пока счет открыт, удаляет начисление
+CREATE TABLE IF NOT EXISTS tabs (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	event_id uuid NOT NULL REFERENCES events (id),
+	guest_name text NOT NULL,
+	status text NOT NULL,
+	shares jsonb,
+	opened_at timestamptz NOT NULL DEFAULT now(),
+	closed_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS tabs_event_idx ON tabs (event_id, opened_at);
+
+CREATE TABLE IF NOT EXISTS tab_charges (
+	tab_id uuid NOT NULL REFERENCES tabs (id) ON DELETE CASCADE,
+	order_id uuid NOT NULL UNIQUE REFERENCES orders (id) ON DELETE CASCADE,
+	amount bigint NOT NULL,
+	charged_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS tab_charges_tab_idx ON tab_charges (tab_id);
+
+DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'orders_tab_id_fkey') THEN
+		ALTER TABLE orders ADD CONSTRAINT orders_tab_id_fkey FOREIGN KEY (tab_id) REFERENCES tabs (id);
+	END IF;
+END $$;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS bars_session_token_idx;
+ALTER TABLE bars DROP COLUMN IF EXISTS session_token;
@@ -0,0 +1,4 @@
+-- Токен сессии бара входит в ссылку для гостей и ищется при подключении к бару
+ALTER TABLE bars ADD COLUMN IF NOT EXISTS session_token text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS bars_session_token_idx ON bars (session_token);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS bar_unavailable_drinks;
+DROP TABLE IF EXISTS bar_staff;
@@ -0,0 +1,18 @@
+-- Бармены, назначенные на бары, и напитки, которые бар временно не готовит
+CREATE TABLE IF NOT EXISTS bar_staff (
+	bar_id integer NOT NULL REFERENCES bars (id) ON DELETE CASCADE,
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	role text NOT NULL,
+	assigned_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (bar_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS bar_staff_user_idx ON bar_staff (user_id);
+
+CREATE TABLE IF NOT EXISTS bar_unavailable_drinks (
+	bar_id integer NOT NULL REFERENCES bars (id) ON DELETE CASCADE,
+	drink_id text NOT NULL,
+	changed_by text NOT NULL,
+	changed_at timestamptz NOT NULL DEFAULT now(),
+	PRIMARY KEY (bar_id, drink_id)
+);
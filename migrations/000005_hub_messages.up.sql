@@ -0,0 +1,8 @@
+-- Сообщения хаба, которые не помещаются в payload NOTIFY. В NOTIFY передается их id
+CREATE TABLE IF NOT EXISTS hub_messages (
+	id bigserial PRIMARY KEY,
+	payload text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS hub_messages_created_at_idx ON hub_messages (created_at);
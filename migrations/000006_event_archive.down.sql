@@ -0,0 +1,4 @@
+ALTER TABLE events DROP COLUMN IF EXISTS report;
+ALTER TABLE events DROP COLUMN IF EXISTS snapshot;
+ALTER TABLE events DROP COLUMN IF EXISTS closed_at;
+ALTER TABLE events DROP COLUMN IF EXISTS end_time;
@@ -0,0 +1,16 @@
+-- Время окончания ивента, по которому он завершается, и архив завершенного ивента:
+-- снимок меню и ингредиентов и отчет
+DO $$
+BEGIN
+	-- end_time хранится так же, как date_time, чтобы их переводил в UTC один и тот же код
+	IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+		WHERE table_name = 'events' AND column_name = 'end_time') THEN
+		EXECUTE format('ALTER TABLE events ADD COLUMN end_time %s', (
+			SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+			WHERE attrelid = 'events'::regclass AND attname = 'date_time'));
+	END IF;
+END $$;
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS closed_at timestamptz;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS snapshot jsonb;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS report jsonb;
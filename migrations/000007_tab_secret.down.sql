@@ -0,0 +1 @@
+ALTER TABLE tabs DROP COLUMN IF EXISTS secret;
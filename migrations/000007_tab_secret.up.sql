@@ -0,0 +1,3 @@
+-- Секрет счета выдается гостю при открытии счета и нужен, чтобы начислить на счет заказ.
+-- У счетов, открытых раньше, секрета нет: начислять на них новые заказы нельзя
+ALTER TABLE tabs ADD COLUMN IF NOT EXISTS secret text;
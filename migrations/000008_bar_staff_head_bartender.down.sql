@@ -0,0 +1 @@
+DROP INDEX IF EXISTS bar_staff_head_bartender_idx;
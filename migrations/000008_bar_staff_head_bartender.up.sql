@@ -0,0 +1,4 @@
+-- В баре не больше одного старшего бармена
+CREATE UNIQUE INDEX IF NOT EXISTS bar_staff_head_bartender_idx
+	ON bar_staff (bar_id)
+	WHERE role = 'head_bartender';
@@ -0,0 +1,19 @@
+-- Время ивентов снова хранится как показания часов Москвы без пояса,
+-- как до появления часовых поясов
+DO $$
+BEGIN
+	IF (SELECT data_type FROM information_schema.columns
+		WHERE table_name = 'events' AND column_name = 'date_time') = 'timestamp with time zone' THEN
+		UPDATE events
+		SET
+			date_time = (date_time AT TIME ZONE 'Europe/Moscow') AT TIME ZONE 'UTC',
+			end_time = (end_time AT TIME ZONE 'Europe/Moscow') AT TIME ZONE 'UTC';
+	ELSE
+		UPDATE events
+		SET
+			date_time = (date_time AT TIME ZONE 'UTC') AT TIME ZONE 'Europe/Moscow',
+			end_time = (end_time AT TIME ZONE 'UTC') AT TIME ZONE 'Europe/Moscow';
+	END IF;
+END $$;
+
+ALTER TABLE events DROP COLUMN IF EXISTS timezone;
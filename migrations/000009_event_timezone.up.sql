@@ -0,0 +1,27 @@
+-- Ивенты, созданные до появления часовых поясов, хранят показания часов Москвы без пояса.
+-- Время таких ивентов переводится в UTC, а пояс проставляется явно,
+-- чтобы они читались и планировались так же, как новые
+ALTER TABLE events ADD COLUMN IF NOT EXISTS timezone text;
+
+DO $$
+BEGIN
+	IF (SELECT data_type FROM information_schema.columns
+		WHERE table_name = 'events' AND column_name = 'date_time') = 'timestamp with time zone' THEN
+		-- показания часов сохранены как время UTC
+		UPDATE events
+		SET
+			date_time = (date_time AT TIME ZONE 'UTC') AT TIME ZONE 'Europe/Moscow',
+			end_time = (end_time AT TIME ZONE 'UTC') AT TIME ZONE 'Europe/Moscow',
+			timezone = 'Europe/Moscow'
+		WHERE
+			timezone IS NULL;
+	ELSE
+		UPDATE events
+		SET
+			date_time = (date_time AT TIME ZONE 'Europe/Moscow') AT TIME ZONE 'UTC',
+			end_time = (end_time AT TIME ZONE 'Europe/Moscow') AT TIME ZONE 'UTC',
+			timezone = 'Europe/Moscow'
+		WHERE
+			timezone IS NULL;
+	END IF;
+END $$;
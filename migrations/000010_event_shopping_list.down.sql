@@ -0,0 +1,32 @@
+-- Список покупок снова хранит только названия ингредиентов в text[].
+-- Количества и единицы позиций при этом теряются
+ALTER TABLE events DROP COLUMN IF EXISTS shopping_diff;
+ALTER TABLE events DROP COLUMN IF EXISTS reviewed_shopping_list;
+ALTER TABLE events DROP COLUMN IF EXISTS drinks_per_guest;
+
+DO $$
+BEGIN
+	IF (SELECT data_type FROM information_schema.columns
+		WHERE table_name = 'events' AND column_name = 'shopping_list') = 'jsonb' THEN
+		-- в ALTER COLUMN ... USING нельзя использовать подзапрос, поэтому колонка пересоздается
+		ALTER TABLE events ADD COLUMN shopping_names text[];
+
+		UPDATE events
+		SET
+			shopping_names = (
+				SELECT
+					COALESCE(array_agg(
+						CASE WHEN jsonb_typeof(item) = 'string'
+							THEN item #>> '{}'
+							ELSE item ->> 'name'
+						END ORDER BY ord), '{}')
+				FROM
+					jsonb_array_elements(shopping_list) WITH ORDINALITY AS l(item, ord)
+			)
+		WHERE
+			jsonb_typeof(shopping_list) = 'array';
+
+		ALTER TABLE events DROP COLUMN shopping_list;
+		ALTER TABLE events RENAME COLUMN shopping_names TO shopping_list;
+	END IF;
+END $$;
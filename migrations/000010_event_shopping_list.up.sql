@@ -0,0 +1,48 @@
+-- Список покупок ивента хранится позициями с типом, названием, единицей и количеством.
+-- Ивенты, созданные раньше, хранят только названия ингредиентов: они становятся позициями
+-- без типа и количества, а при следующем пересчете списка попадают в разницу списков
+ALTER TABLE events ADD COLUMN IF NOT EXISTS drinks_per_guest jsonb;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS reviewed_shopping_list jsonb;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS shopping_diff jsonb;
+
+DO $$
+BEGIN
+	IF (SELECT data_type FROM information_schema.columns
+		WHERE table_name = 'events' AND column_name = 'shopping_list') = 'ARRAY' THEN
+		-- в ALTER COLUMN ... USING нельзя использовать подзапрос, поэтому колонка пересоздается
+		ALTER TABLE events ADD COLUMN shopping_items jsonb;
+
+		UPDATE events
+		SET
+			shopping_items = (
+				SELECT
+					COALESCE(jsonb_agg(jsonb_build_object('type', '', 'name', name, 'amount', 0) ORDER BY ord), '[]')
+				FROM
+					unnest(shopping_list) WITH ORDINALITY AS l(name, ord)
+			)
+		WHERE
+			shopping_list IS NOT NULL;
+
+		ALTER TABLE events DROP COLUMN shopping_list;
+		ALTER TABLE events RENAME COLUMN shopping_items TO shopping_list;
+	ELSE
+		-- список названий уже сохранен в jsonb
+		UPDATE events
+		SET
+			shopping_list = (
+				SELECT
+					jsonb_agg(
+						CASE WHEN jsonb_typeof(item) = 'string'
+							THEN jsonb_build_object('type', '', 'name', item #>> '{}', 'amount', 0)
+							ELSE item
+						END ORDER BY ord)
+				FROM
+					jsonb_array_elements(shopping_list) WITH ORDINALITY AS l(item, ord)
+			)
+		WHERE
+			jsonb_typeof(shopping_list) = 'array'
+			AND EXISTS (
+				SELECT 1 FROM jsonb_array_elements(shopping_list) AS l(item) WHERE jsonb_typeof(item) = 'string'
+			);
+	END IF;
+END $$;
@@ -0,0 +1,46 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+
+	qr "github.com/skip2/go-qrcode"
+)
+
+// Уровень коррекции ошибок: карточки на столах пачкаются и мнутся
+const recoveryLevel = qr.Medium
+
+// PNG рисует QR-код содержимого квадратной картинкой size x size пикселей
+func PNG(content string, size int) ([]byte, error) {
+	return qr.Encode(content, recoveryLevel, size)
+}
+
+// SVG рисует QR-код содержимого векторной картинкой со стороной size.
+// Каждый черный модуль - квадрат 1x1 в системе координат viewBox, включая белую рамку
+func SVG(content string, size int) ([]byte, error) {
+	code, err := qr.New(content, recoveryLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := code.Bitmap()
+	modules := len(bitmap)
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, modules, modules)
+
+	for y, row := range bitmap {
+		for x, black := range row {
+			if black {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes(), nil
+}
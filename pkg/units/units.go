@@ -0,0 +1,152 @@
+// Package units описывает единицы измерения количеств в рецептах и ингредиентах.
+// Количества разных единиц складываются только после перевода в базовую единицу своей величины
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Unit string
+
+const (
+	// volume
+	ML   Unit = "ml"
+	CL   Unit = "cl"
+	L    Unit = "l"
+	OZ   Unit = "oz"
+	Dash Unit = "dash"
+
+	// mass
+	G  Unit = "g"
+	KG Unit = "kg"
+
+	// count
+	Pcs Unit = "pcs"
+)
+
+type Dimension string
+
+const (
+	Volume Dimension = "volume"
+	Mass   Dimension = "mass"
+	Count  Dimension = "count"
+)
+
+type unitInfo struct {
+	dimension Dimension
+	// сколько базовых единиц величины в одной единице
+	factor float64
+}
+
+// Базовые единицы: ml для объема, g для массы, pcs для штук.
+// oz - американская жидкая унция, dash - 1/32 унции
+var known = map[Unit]unitInfo{
+	ML:   {Volume, 1},
+	CL:   {Volume, 10},
+	L:    {Volume, 1000},
+	OZ:   {Volume, 29.5735},
+	Dash: {Volume, 29.5735 / 32},
+	G:    {Mass, 1},
+	KG:   {Mass, 1000},
+	Pcs:  {Count, 1},
+}
+
+var base = map[Dimension]Unit{
+	Volume: ML,
+	Mass:   G,
+	Count:  Pcs,
+}
+
+// Написания, которые приходят от пользователей
+var aliases = map[string]Unit{
+	"ml": ML, "мл": ML, "milliliter": ML, "millilitre": ML,
+	"cl": CL, "сл": CL,
+	"l": L, "л": L, "liter": L, "litre": L,
+	"oz": OZ, "унц": OZ, "унция": OZ, "fl oz": OZ,
+	"dash": Dash, "dashes": Dash, "дэш": Dash, "деш": Dash,
+	"g": G, "г": G, "гр": G, "gram": G,
+	"kg": KG, "кг": KG,
+	"pcs": Pcs, "pc": Pcs, "шт": Pcs,
+}
+
+// Parse приводит написание единицы к каноническому: "мл", "ML" и "ml." дают ML
+func Parse(s string) (Unit, error) {
+	name := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+
+	unit, ok := aliases[name]
+	if !ok {
+		return "", fmt.Errorf("unknown unit: %q", s)
+	}
+
+	return unit, nil
+}
+
+func (u Unit) Dimension() Dimension {
+	return known[u].dimension
+}
+
+// Base возвращает базовую единицу величины, к которой относится u
+func (u Unit) Base() Unit {
+	return base[u.Dimension()]
+}
+
+// ParseAs разбирает единицу и проверяет, что она измеряет одну из величин dims
+func ParseAs(s string, dims ...Dimension) (Unit, error) {
+	unit, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	for _, dim := range dims {
+		if unit.Dimension() == dim {
+			return unit, nil
+		}
+	}
+
+	return "", fmt.Errorf("unit %s is not a unit of %s", unit, joinDimensions(dims))
+}
+
+// Convert переводит количество из одной единицы в другую той же величины
+func Convert(amount float64, from, to Unit) (float64, error) {
+	fromInfo, ok := known[from]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit: %q", from)
+	}
+
+	toInfo, ok := known[to]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit: %q", to)
+	}
+
+	if fromInfo.dimension != toInfo.dimension {
+		return 0, fmt.Errorf("can't convert %s to %s", from, to)
+	}
+
+	return amount * fromInfo.factor / toInfo.factor, nil
+}
+
+// Normalize переводит количество в базовую единицу его величины.
+// Единица может быть записана любым известным написанием
+func Normalize(amount float64, unit string) (float64, Unit, error) {
+	u, err := Parse(unit)
+	if err != nil {
+		return 0, "", err
+	}
+
+	normalized, err := Convert(amount, u, u.Base())
+	if err != nil {
+		return 0, "", err
+	}
+
+	return normalized, u.Base(), nil
+}
+
+func joinDimensions(dims []Dimension) string {
+	names := make([]string, len(dims))
+	for i, dim := range dims {
+		names[i] = string(dim)
+	}
+
+	return strings.Join(names, " or ")
+}
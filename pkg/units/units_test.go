@@ -0,0 +1,128 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Unit
+		wantErr bool
+	}{
+		{"ml", ML, false},
+		{"ML", ML, false},
+		{" мл. ", ML, false},
+		{"fl oz", OZ, false},
+		{"Dashes", Dash, false},
+		{"кг", KG, false},
+		{"шт", Pcs, false},
+		{"", "", true},
+		{"cup", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseAs(t *testing.T) {
+	tests := []struct {
+		in      string
+		dims    []Dimension
+		want    Unit
+		wantErr bool
+	}{
+		{"cl", []Dimension{Volume}, CL, false},
+		{"g", []Dimension{Mass, Volume}, G, false},
+		{"l", []Dimension{Mass, Volume}, L, false},
+		{"l", []Dimension{Mass}, "", true},
+		{"pcs", []Dimension{Volume}, "", true},
+		{"cup", []Dimension{Volume}, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseAs(tt.in, tt.dims...)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAs(%q, %v) error = %v, wantErr %v", tt.in, tt.dims, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("ParseAs(%q, %v) = %s, want %s", tt.in, tt.dims, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		amount  float64
+		from    Unit
+		to      Unit
+		want    float64
+		wantErr bool
+	}{
+		{1, L, ML, 1000, false},
+		{5, CL, ML, 50, false},
+		{250, ML, L, 0.25, false},
+		{2, OZ, ML, 59.147, false},
+		{32, Dash, OZ, 1, false},
+		{1.5, KG, G, 1500, false},
+		{3, Pcs, Pcs, 3, false},
+		{1, L, G, 0, true},
+		{1, KG, Pcs, 0, true},
+		{1, Unit("cup"), ML, 0, true},
+		{1, ML, Unit("cup"), 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Convert(tt.amount, tt.from, tt.to)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Convert(%v, %s, %s) error = %v, wantErr %v", tt.amount, tt.from, tt.to, err, tt.wantErr)
+			continue
+		}
+
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Convert(%v, %s, %s) = %v, want %v", tt.amount, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		unit     string
+		want     float64
+		wantBase Unit
+		wantErr  bool
+	}{
+		{2, "л", 2000, ML, false},
+		{4, "cl", 40, ML, false},
+		{1, "oz", 29.5735, ML, false},
+		{0.5, "кг", 500, G, false},
+		{7, "g", 7, G, false},
+		{3, "шт", 3, Pcs, false},
+		{1, "капля", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		got, base, err := Normalize(tt.amount, tt.unit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Normalize(%v, %q) error = %v, wantErr %v", tt.amount, tt.unit, err, tt.wantErr)
+			continue
+		}
+
+		if math.Abs(got-tt.want) > 1e-9 || base != tt.wantBase {
+			t.Errorf("Normalize(%v, %q) = %v %s, want %v %s", tt.amount, tt.unit, got, base, tt.want, tt.wantBase)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Package xlsx пишет книгу XLSX построчно прямо в io.Writer.
+// Листы пишутся по очереди, строки не хранятся в памяти
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	contentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+%s</Types>`
+	sheetContentType = `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+`
+
+	rootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+	workbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>
+%s</sheets>
+</workbook>`
+	workbookSheet = `<sheet name="%s" sheetId="%d" r:id="rId%d"/>
+`
+
+	workbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+%s</Relationships>`
+	workbookSheetRel = `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>
+`
+
+	sheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	sheetFooter = `</sheetData></worksheet>`
+
+	// Excel не принимает имена листов длиннее 31 символа
+	maxSheetName = 31
+)
+
+// Writer пишет книгу. Сначала вызывается NewSheet, затем WriteRow для строк этого листа.
+// Close дописывает книгу и обязателен
+type Writer struct {
+	zw     *zip.Writer
+	sheets []string
+	sheet  io.Writer
+	row    int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// NewSheet завершает текущий лист и начинает новый
+func (w *Writer) NewSheet(name string) error {
+	if err := w.closeSheet(); err != nil {
+		return err
+	}
+
+	if len([]rune(name)) > maxSheetName {
+		name = string([]rune(name)[:maxSheetName])
+	}
+
+	w.sheets = append(w.sheets, name)
+
+	sheet, err := w.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(w.sheets)))
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(sheet, sheetHeader); err != nil {
+		return err
+	}
+
+	w.sheet = sheet
+	w.row = 0
+
+	return nil
+}
+
+// WriteRow пишет строку текущего листа. Числа записываются числовыми ячейками,
+// время - текстом в RFC 3339, остальное - текстом
+func (w *Writer) WriteRow(cells ...any) error {
+	if w.sheet == nil {
+		return fmt.Errorf("xlsx: no sheet to write the row to")
+	}
+
+	w.row++
+
+	if _, err := fmt.Fprintf(w.sheet, `<row r="%d">`, w.row); err != nil {
+		return err
+	}
+
+	for i, cell := range cells {
+		if err := w.writeCell(cellRef(i, w.row), cell); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w.sheet, `</row>`)
+	return err
+}
+
+func (w *Writer) writeCell(ref string, cell any) error {
+	var number string
+
+	switch v := cell.(type) {
+	case int:
+		number = strconv.Itoa(v)
+	case int64:
+		number = strconv.FormatInt(v, 10)
+	case uint32:
+		number = strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		number = strconv.FormatUint(v, 10)
+	case float64:
+		number = strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return w.writeString(ref, v.Format(time.RFC3339))
+	case string:
+		return w.writeString(ref, v)
+	default:
+		return w.writeString(ref, fmt.Sprint(v))
+	}
+
+	_, err := fmt.Fprintf(w.sheet, `<c r="%s"><v>%s</v></c>`, ref, number)
+	return err
+}
+
+func (w *Writer) writeString(ref, value string) error {
+	if _, err := fmt.Fprintf(w.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref); err != nil {
+		return err
+	}
+
+	if err := xml.EscapeText(w.sheet, []byte(value)); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w.sheet, `</t></is></c>`)
+	return err
+}
+
+func (w *Writer) closeSheet() error {
+	if w.sheet == nil {
+		return nil
+	}
+
+	_, err := io.WriteString(w.sheet, sheetFooter)
+	w.sheet = nil
+
+	return err
+}
+
+// Close завершает последний лист и записывает описание книги
+func (w *Writer) Close() error {
+	if err := w.closeSheet(); err != nil {
+		return err
+	}
+
+	var types, sheets, rels string
+	for i, name := range w.sheets {
+		types += fmt.Sprintf(sheetContentType, i+1)
+		sheets += fmt.Sprintf(workbookSheet, escape(name), i+1, i+1)
+		rels += fmt.Sprintf(workbookSheetRel, i+1, i+1)
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", fmt.Sprintf(contentTypes, types)},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", fmt.Sprintf(workbook, sheets)},
+		{"xl/_rels/workbook.xml.rels", fmt.Sprintf(workbookRels, rels)},
+	}
+
+	for _, file := range files {
+		f, err := w.zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(f, file.content); err != nil {
+			return err
+		}
+	}
+
+	return w.zw.Close()
+}
+
+// cellRef возвращает адрес ячейки вида A1 по номеру колонки (с нуля) и строки (с единицы)
+func cellRef(col, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+
+	return name + strconv.Itoa(row)
+}
+
+func escape(s string) string {
+	var buf []byte
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf = append(buf, "&amp;"...)
+		case '<':
+			buf = append(buf, "&lt;"...)
+		case '>':
+			buf = append(buf, "&gt;"...)
+		case '"':
+			buf = append(buf, "&quot;"...)
+		default:
+			buf = append(buf, string(r)...)
+		}
+	}
+
+	return string(buf)
+}
@@ -0,0 +1,173 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCellRef(t *testing.T) {
+	tests := []struct {
+		col  int
+		row  int
+		want string
+	}{
+		{0, 1, "A1"},
+		{1, 2, "B2"},
+		{25, 10, "Z10"},
+		{26, 1, "AA1"},
+		{27, 1, "AB1"},
+		{51, 1, "AZ1"},
+		{52, 1, "BA1"},
+		{701, 3, "ZZ3"},
+		{702, 3, "AAA3"},
+	}
+
+	for _, tt := range tests {
+		if got := cellRef(tt.col, tt.row); got != tt.want {
+			t.Errorf("cellRef(%d, %d) = %s, want %s", tt.col, tt.row, got, tt.want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"orders", "orders"},
+		{"a & b", "a &amp; b"},
+		{"<bar>", "&lt;bar&gt;"},
+		{`"top"`, "&quot;top&quot;"},
+		{"коктейли", "коктейли"},
+	}
+
+	for _, tt := range tests {
+		if got := escape(tt.in); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteRowWithoutSheet(t *testing.T) {
+	w := NewWriter(io.Discard)
+
+	if err := w.WriteRow("a"); err == nil {
+		t.Fatal("expected an error for a row without a sheet")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	longName := strings.Repeat("я", maxSheetName+5)
+	at := time.Date(2024, 5, 1, 18, 30, 0, 0, time.UTC)
+
+	w := NewWriter(&buf)
+	steps := []struct {
+		sheet string
+		rows  [][]any
+	}{
+		{"orders", [][]any{
+			{"order_id", "quantity", "created_at"},
+			{"1", uint32(2), at},
+			{"<b>&", 1.5, int64(-3)},
+		}},
+		{longName, [][]any{{"total", 10}}},
+	}
+
+	for _, step := range steps {
+		if err := w.NewSheet(step.sheet); err != nil {
+			t.Fatalf("NewSheet(%s): %v", step.sheet, err)
+		}
+
+		for _, row := range step.rows {
+			if err := w.WriteRow(row...); err != nil {
+				t.Fatalf("WriteRow: %v", err)
+			}
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reading zip: %v", err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", f.Name, err)
+		}
+
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", f.Name, err)
+		}
+
+		files[f.Name] = string(content)
+	}
+
+	tests := []struct {
+		file     string
+		contains []string
+	}{
+		{"[Content_Types].xml", []string{"/xl/worksheets/sheet1.xml", "/xl/worksheets/sheet2.xml"}},
+		{"_rels/.rels", []string{`Target="xl/workbook.xml"`}},
+		{"xl/workbook.xml", []string{
+			`<sheet name="orders" sheetId="1" r:id="rId1"/>`,
+			`<sheet name="` + strings.Repeat("я", maxSheetName) + `" sheetId="2" r:id="rId2"/>`,
+		}},
+		{"xl/_rels/workbook.xml.rels", []string{`Target="worksheets/sheet2.xml"`}},
+		{"xl/worksheets/sheet1.xml", []string{
+			`<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">order_id</t></is></c>`,
+			`<c r="B2"><v>2</v></c>`,
+			`<c r="C2" t="inlineStr"><is><t xml:space="preserve">2024-05-01T18:30:00Z</t></is></c>`,
+			`<t xml:space="preserve">&lt;b&gt;&amp;</t>`,
+			`<c r="B3"><v>1.5</v></c>`,
+			`<c r="C3"><v>-3</v></c>`,
+		}},
+		{"xl/worksheets/sheet2.xml", []string{`<row r="1">`, `<c r="B1"><v>10</v></c>`}},
+	}
+
+	for _, tt := range tests {
+		content, ok := files[tt.file]
+		if !ok {
+			t.Errorf("file %s is missing", tt.file)
+			continue
+		}
+
+		if err := wellFormed(content); err != nil {
+			t.Errorf("file %s is not well-formed xml: %v", tt.file, err)
+		}
+
+		for _, s := range tt.contains {
+			if !strings.Contains(content, s) {
+				t.Errorf("file %s does not contain %s", tt.file, s)
+			}
+		}
+	}
+}
+
+func wellFormed(content string) error {
+	d := xml.NewDecoder(strings.NewReader(content))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}